@@ -0,0 +1,72 @@
+package actions
+
+import (
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/neuralinkcorp/tsui/config"
+	"github.com/neuralinkcorp/tsui/libts"
+	"tailscale.com/ipn/ipnstate"
+)
+
+// Returns true if an action should be shown on a peer, according to its tag and OS filters.
+func Matches(action config.ActionConfig, peer *ipnstate.PeerStatus) bool {
+	if len(action.Tags) > 0 {
+		if !peer.IsTagged() || !peer.Tags.ContainsFunc(func(tag string) bool {
+			return slices.Contains(action.Tags, tag)
+		}) {
+			return false
+		}
+	}
+
+	if len(action.OS) > 0 {
+		if !slices.ContainsFunc(action.OS, func(osName string) bool {
+			return strings.EqualFold(osName, peer.OS)
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Get the actions to show on a peer, in the order they're configured.
+func For(actions []config.ActionConfig, peer *ipnstate.PeerStatus) []config.ActionConfig {
+	return slices.DeleteFunc(slices.Clone(actions), func(action config.ActionConfig) bool {
+		return !Matches(action, peer)
+	})
+}
+
+// Quote a string for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Fill in an action's command template with a peer's values. Peer names come from other
+// machines on the tailnet, so every value is quoted to keep it from being run as code.
+func Expand(command string, peer *ipnstate.PeerStatus) string {
+	ip := ""
+	if len(peer.TailscaleIPs) > 0 {
+		ip = peer.TailscaleIPs[0].String()
+	}
+
+	tags := ""
+	if peer.IsTagged() {
+		tags = strings.Join(peer.Tags.AsSlice(), ",")
+	}
+
+	replacer := strings.NewReplacer(
+		"{ip}", shellQuote(ip),
+		"{name}", shellQuote(libts.PeerName(peer)),
+		"{fqdn}", shellQuote(strings.TrimSuffix(peer.DNSName, ".")),
+		"{os}", shellQuote(peer.OS),
+		"{tags}", shellQuote(tags),
+	)
+	return replacer.Replace(command)
+}
+
+// Create the process that runs an action against a peer.
+func Command(action config.ActionConfig, peer *ipnstate.PeerStatus) *exec.Cmd {
+	return exec.Command("sh", "-c", Expand(action.Command, peer))
+}
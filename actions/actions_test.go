@@ -0,0 +1,87 @@
+package actions
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/neuralinkcorp/tsui/config"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/views"
+)
+
+func TestFor(t *testing.T) {
+	actions := []config.ActionConfig{
+		{Label: "Ping", Command: "ping {ip}"},
+		{Label: "Restart nginx", Command: "ssh {fqdn} sudo systemctl restart nginx", Tags: []string{"tag:web", "tag:proxy"}},
+		{Label: "Screen Share", Command: "open vnc://{fqdn}", OS: []string{"macOS"}},
+		{Label: "Journal", Command: "ssh {fqdn} journalctl -f", Tags: []string{"tag:web"}, OS: []string{"linux"}},
+	}
+
+	webTags := views.SliceOf([]string{"tag:prod", "tag:web"})
+	tests := []struct {
+		peer ipnstate.PeerStatus
+		want string
+	}{
+		{ipnstate.PeerStatus{DNSName: "web.", OS: "linux", Tags: &webTags}, "Ping, Restart nginx, Journal"},
+		{ipnstate.PeerStatus{DNSName: "web-bsd.", OS: "freebsd", Tags: &webTags}, "Ping, Restart nginx"},
+		// OS names match regardless of case.
+		{ipnstate.PeerStatus{DNSName: "laptop.", OS: "macos"}, "Ping, Screen Share"},
+		{ipnstate.PeerStatus{DNSName: "desktop.", OS: "linux"}, "Ping"},
+	}
+
+	for _, test := range tests {
+		got := ""
+		for i, action := range For(actions, &test.peer) {
+			if i > 0 {
+				got += ", "
+			}
+			got += action.Label
+		}
+		if got != test.want {
+			t.Errorf("actions for %s: got %q, want %q", test.peer.DNSName, got, test.want)
+		}
+	}
+
+	if len(actions) != 4 || actions[1].Label != "Restart nginx" {
+		t.Errorf("For modified the configured actions: %+v", actions)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tags := views.SliceOf([]string{"tag:web", "tag:prod"})
+	peer := &ipnstate.PeerStatus{
+		DNSName:      "web-1.example.ts.net.",
+		OS:           "linux",
+		Tags:         &tags,
+		TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.101.102.103"), netip.MustParseAddr("fd7a:115c:a1e0::1")},
+	}
+
+	got := Expand("ssh {name} -- ping -c1 {ip} # {fqdn} {os} {tags} {unknown}", peer)
+	want := "ssh 'web-1' -- ping -c1 '100.101.102.103' # 'web-1.example.ts.net' 'linux' 'tag:web,tag:prod' {unknown}"
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	// Missing values still expand to an (empty) argument.
+	if got := Expand("echo {ip} {tags}", &ipnstate.PeerStatus{}); got != "echo '' ''" {
+		t.Errorf("got %s for a peer with no IPs or tags", got)
+	}
+}
+
+func TestCommandQuoting(t *testing.T) {
+	// Peer values come from other machines, so anything the shell would interpret must be
+	// passed through literally.
+	hostile := `x'; touch pwned; echo '$(id) "quoted" \ *`
+	peer := &ipnstate.PeerStatus{DNSName: "evil.example.ts.net.", OS: hostile}
+
+	cmd := Command(config.ActionConfig{Command: "printf '%s|' {name} {os}"}, peer)
+	cmd.Dir = t.TempDir()
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(output), "evil|"+hostile+"|"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package browser
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Returns true if we can (probably) open a URL in the user's web browser. This needs a
+// graphical session and xdg-open, and doesn't work as root, since the browser would run as
+// root too (and usually refuses to).
+func CanOpen() bool {
+	if os.Geteuid() == 0 {
+		return false
+	}
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return false
+	}
+	_, err := exec.LookPath("xdg-open")
+	return err == nil
+}
+
+// Open a URL in the user's web browser.
+func Open(url string) error {
+	// Output is discarded, since some browsers print to the terminal and would mess up the UI.
+	cmd := exec.Command("xdg-open", url)
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	// Don't wait for the browser, but reap the process when it exits.
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package browser
+
+import "errors"
+
+// Returns true if we can (probably) open a URL in the user's web browser. Only supported on
+// Linux; on macOS, Tailscale opens the browser itself.
+func CanOpen() bool {
+	return false
+}
+
+// Open a URL in the user's web browser.
+func Open(url string) error {
+	return errors.New("opening a browser is not supported on this platform")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/neuralinkcorp/tsui/config"
+	"github.com/neuralinkcorp/tsui/export"
+	"github.com/neuralinkcorp/tsui/libts"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/ipn"
+)
+
+// Run a non-interactive subcommand, like `tsui whois 100.101.102.103`.
+func runSubcommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "whois":
+		return runWhoIsCommand(args[1:])
+	case "export":
+		return runExportCommand(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// Get the capability names from a whois response, sorted.
+func whoIsCapabilities(resp *apitype.WhoIsResponse) []string {
+	caps := make([]string, 0, len(resp.CapMap))
+	for capability := range resp.CapMap {
+		caps = append(caps, string(capability))
+	}
+	slices.Sort(caps)
+	return caps
+}
+
+// Print who owns a Tailscale address.
+func runWhoIsCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: tsui whois <ip[:port]>")
+	}
+
+	resp, err := libts.WhoIs(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Machine:")
+	fmt.Fprintf(w, "  Name:\t%s\n", strings.TrimSuffix(resp.Node.Name, "."))
+	fmt.Fprintf(w, "  ID:\t%s\n", resp.Node.StableID)
+	fmt.Fprintf(w, "  Addresses:\t%s\n", resp.Node.Addresses)
+	if resp.Node.IsTagged() {
+		fmt.Fprintf(w, "  Tags:\t%s\n", strings.Join(resp.Node.Tags, ", "))
+	} else if resp.UserProfile != nil {
+		fmt.Fprintln(w, "Owner:")
+		fmt.Fprintf(w, "  Name:\t%s\n", resp.UserProfile.DisplayName)
+		fmt.Fprintf(w, "  Login:\t%s\n", resp.UserProfile.LoginName)
+	}
+
+	if caps := whoIsCapabilities(resp); len(caps) > 0 {
+		fmt.Fprintln(w, "Capabilities:")
+		for _, capability := range caps {
+			fmt.Fprintf(w, "  - %s\n", capability)
+		}
+	}
+
+	return w.Flush()
+}
+
+// Print or save peers in a format like an Ansible inventory.
+func runExportCommand(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := flags.String("format", "", "output format: hosts, ssh-config, ansible, or csv")
+	output := flags.String("output", "", "file to write to instead of standard output")
+	var filter export.Filter
+	flags.StringVar(&filter.Owner, "owner", "", "only export peers owned by this login or display name")
+	flags.StringVar(&filter.Tag, "tag", "", "only export peers with this tag, like tag:server")
+	flags.StringVar(&filter.OS, "os", "", "only export peers running this OS, like linux")
+	flags.BoolVar(&filter.OnlineOnly, "online", false, "only export peers that are online")
+
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		// The flag package already printed the usage.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return errors.New("usage: tsui export --format <hosts|ssh-config|ansible|csv> [flags]")
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	state, err := libts.GetState(ctx)
+	if err != nil {
+		return err
+	}
+	if state.BackendState != ipn.Running {
+		return fmt.Errorf("can't export peers while Tailscale is %s", state.BackendState)
+	}
+
+	peers := export.Peers(state, filter)
+
+	if *output == "" {
+		return export.Write(os.Stdout, format, state, peers, cfg.SSH)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = export.Write(file, format, state, peers, cfg.SSH)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
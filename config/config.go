@@ -0,0 +1,193 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// User configuration for tsui, loaded from a JSON file. Every field is optional.
+type Config struct {
+	SSH SSHConfig `json:"ssh"`
+	// Path of the tailscaled socket to connect to. If empty, uses the platform default.
+	Socket string `json:"socket"`
+	// Path of a file to append the event log to. If empty, events are only kept in memory.
+	LogFile string `json:"logFile"`
+	// Desktop notification settings.
+	Notifications NotificationsConfig `json:"notifications"`
+	// Start warning about this device's key expiring when it's this close to expiry.
+	KeyExpiryWarning Duration `json:"keyExpiryWarning"`
+	// Custom actions shown on peers.
+	Actions []ActionConfig `json:"actions"`
+	// Default copy formats.
+	Copy CopyConfig `json:"copy"`
+}
+
+// A duration written in the config file as a string like "72h".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// Configuration for the SSH quick-connect action.
+type SSHConfig struct {
+	// User to log in as on every peer. If empty, ssh picks (usually the local username).
+	DefaultUser string `json:"defaultUser"`
+	// Users to log in as on specific peers, keyed by peer name like "foobar-router-2"
+	// or by stable node ID. Overrides DefaultUser.
+	Users map[string]string `json:"users"`
+}
+
+// A way to format a device for copying to the clipboard.
+type CopyFormat string
+
+const (
+	CopyIPv4 CopyFormat = "ipv4"
+	CopyIPv6 CopyFormat = "ipv6"
+	// Short MagicDNS name, like "foobar-router-2".
+	CopyName CopyFormat = "name"
+	// Full MagicDNS name, like "foobar-router-2.tail1234.ts.net".
+	CopyFQDN CopyFormat = "fqdn"
+	// Command to SSH into the device, like "ssh root@foobar-router-2.tail1234.ts.net".
+	CopySSH CopyFormat = "ssh"
+	// Web URL, like "http://foobar-router-2.tail1234.ts.net".
+	CopyURL CopyFormat = "url"
+	// Stable node ID.
+	CopyID CopyFormat = "id"
+)
+
+// All copy formats, in the order they're shown.
+var CopyFormats = []CopyFormat{CopyIPv4, CopyIPv6, CopyName, CopyFQDN, CopySSH, CopyURL, CopyID}
+
+func (f *CopyFormat) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(CopyFormats, CopyFormat(s)) {
+		return fmt.Errorf("unknown copy format %q", s)
+	}
+
+	*f = CopyFormat(s)
+	return nil
+}
+
+// Default formats for copying devices. Every format is always available in the "Copy As"
+// submenus; these pick the one used by the quick copy item.
+type CopyConfig struct {
+	// Format for this device, in the This Device menu.
+	ThisDevice CopyFormat `json:"thisDevice"`
+	// Format for other devices on the tailnet.
+	Peers CopyFormat `json:"peers"`
+}
+
+// A custom action that runs a command against a peer, like opening its web interface.
+type ActionConfig struct {
+	// Label shown on the peer, like "Open Grafana".
+	Label string `json:"label"`
+	// Shell command to run. {ip}, {name}, {fqdn}, {os}, and {tags} are replaced with the
+	// peer's values, quoted for the shell.
+	Command string `json:"command"`
+	// Only show the action on peers with at least one of these tags, like "tag:server".
+	Tags []string `json:"tags"`
+	// Only show the action on peers running one of these OSes, like "linux".
+	OS []string `json:"os"`
+}
+
+// Configuration for desktop notifications. Each event can be turned off individually.
+type NotificationsConfig struct {
+	// Whether to send desktop notifications at all. Off by default.
+	Enabled bool `json:"enabled"`
+	// Notify when Tailscale disconnects, including when tailscaled becomes unreachable.
+	Disconnected bool `json:"disconnected"`
+	// Notify when this device's key is about to expire.
+	KeyExpiry bool `json:"keyExpiry"`
+	// Notify when the current exit node goes offline.
+	ExitNodeOffline bool `json:"exitNodeOffline"`
+	// Notify when this device is locked out by tailnet lock.
+	LockedOut bool `json:"lockedOut"`
+}
+
+// Get the default config, used for any fields missing from the config file.
+func Default() *Config {
+	return &Config{
+		KeyExpiryWarning: Duration(72 * time.Hour),
+		Copy: CopyConfig{
+			ThisDevice: CopyIPv4,
+			Peers:      CopyIPv4,
+		},
+		Notifications: NotificationsConfig{
+			Disconnected:    true,
+			KeyExpiry:       true,
+			ExitNodeOffline: true,
+			LockedOut:       true,
+		},
+	}
+}
+
+// Get the path of the config file, which is tsui/config.json inside the user's config
+// directory (e.g. ~/.config on Linux).
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tsui", "config.json"), nil
+}
+
+// Load the config file. If it doesn't exist, returns the default config.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path, err := Path()
+	if err != nil {
+		// No config directory means no config, which is fine.
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Get the user to SSH into a peer as, given its name and stable node ID.
+// Returns an empty string if ssh should pick.
+func (c *SSHConfig) UserFor(peerName string, peerID string) string {
+	if user, ok := c.Users[peerName]; ok {
+		return user
+	}
+	if user, ok := c.Users[peerID]; ok {
+		return user
+	}
+	return c.DefaultUser
+}
@@ -0,0 +1,126 @@
+package eventlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Severity of an event:
+//
+//	LevelInfo, LevelSuccess, LevelError
+type Level int
+
+const (
+	LevelInfo Level = iota
+	LevelSuccess
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelSuccess:
+		return "OK"
+	case LevelError:
+		return "ERROR"
+	}
+	return "INFO"
+}
+
+// A single logged event.
+type Event struct {
+	// Time the event last occurred.
+	Time time.Time
+	// Severity of the event.
+	Level Level
+	// Human-readable description.
+	Text string
+	// Number of times the event occurred in a row. Repeats are collapsed into one event
+	// so a persistent error doesn't push everything else out of the log.
+	Count int
+}
+
+// A fixed-size ring buffer of events, optionally mirrored to a file.
+type Log struct {
+	// Events in insertion order, wrapping around at start.
+	events []Event
+	// Index of the oldest event once the buffer is full.
+	start int
+	// Log file or nil if events are only kept in memory.
+	file *os.File
+}
+
+// Create an event log holding up to capacity events. If path is non-empty, events are also
+// appended to the file at path, which is created if it doesn't exist.
+func New(capacity int, path string) (*Log, error) {
+	log := &Log{
+		events: make([]Event, 0, capacity),
+	}
+
+	if path != "" {
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			return nil, err
+		}
+
+		log.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open log file: %w", err)
+		}
+	}
+
+	return log, nil
+}
+
+// Record an event that happened now.
+func (l *Log) Add(level Level, text string) {
+	now := time.Now()
+
+	// Write every occurrence to the file, since it isn't size-limited.
+	if l.file != nil {
+		// There's nowhere to report a failed write, so ignore it.
+		_, _ = fmt.Fprintf(l.file, "%s %-5s %s\n", now.Format(time.RFC3339), level, text)
+	}
+
+	if len(l.events) > 0 {
+		last := l.last()
+		if last.Level == level && last.Text == text {
+			last.Time = now
+			last.Count++
+			return
+		}
+	}
+
+	event := Event{Time: now, Level: level, Text: text, Count: 1}
+
+	if len(l.events) < cap(l.events) {
+		l.events = append(l.events, event)
+	} else {
+		l.events[l.start] = event
+		l.start = (l.start + 1) % len(l.events)
+	}
+}
+
+// Get a pointer to the newest event. The log must not be empty.
+func (l *Log) last() *Event {
+	index := (l.start + len(l.events) - 1) % len(l.events)
+	return &l.events[index]
+}
+
+// Get all events, newest first.
+func (l *Log) Events() []Event {
+	events := make([]Event, 0, len(l.events))
+	for i := len(l.events) - 1; i >= 0; i-- {
+		events = append(events, l.events[(l.start+i)%len(l.events)])
+	}
+	return events
+}
+
+// Close the log file, if any.
+func (l *Log) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
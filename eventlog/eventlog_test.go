@@ -0,0 +1,93 @@
+package eventlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Get the text of each event, newest first.
+func texts(log *Log) []string {
+	var texts []string
+	for _, event := range log.Events() {
+		texts = append(texts, event.Text)
+	}
+	return texts
+}
+
+func TestLogCollapsesRepeats(t *testing.T) {
+	log, err := New(10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log.Add(LevelError, "cannot reach tailscaled")
+	log.Add(LevelError, "cannot reach tailscaled")
+	log.Add(LevelError, "cannot reach tailscaled")
+	// Same text at another level is a different event.
+	log.Add(LevelInfo, "cannot reach tailscaled")
+	log.Add(LevelError, "cannot reach tailscaled")
+
+	events := log.Events()
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3: %+v", len(events), events)
+	}
+	if events[0].Count != 1 || events[1].Level != LevelInfo || events[2].Count != 3 {
+		t.Errorf("repeats weren't collapsed correctly: %+v", events)
+	}
+}
+
+func TestLogWrapsAround(t *testing.T) {
+	log, err := New(3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, text := range []string{"a", "b", "c", "d", "e"} {
+		log.Add(LevelInfo, text)
+	}
+	if got := strings.Join(texts(log), ","); got != "e,d,c" {
+		t.Errorf("got events %s, want e,d,c", got)
+	}
+
+	// The newest event is found across the wraparound, so repeats still collapse.
+	log.Add(LevelInfo, "e")
+	if events := log.Events(); len(events) != 3 || events[0].Count != 2 {
+		t.Errorf("repeat after wrapping wasn't collapsed: %+v", events)
+	}
+
+	log.Add(LevelInfo, "f")
+	if got := strings.Join(texts(log), ","); got != "f,e,d" {
+		t.Errorf("got events %s, want f,e,d", got)
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "tsui.log")
+	log, err := New(1, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log.Add(LevelSuccess, "connected")
+	log.Add(LevelError, "disconnected")
+	log.Add(LevelError, "disconnected")
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file keeps every occurrence, even ones collapsed or dropped in memory.
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), data)
+	}
+	if !strings.HasSuffix(lines[0], " OK    connected") || !strings.HasSuffix(lines[2], " ERROR disconnected") {
+		t.Errorf("unexpected log file contents:\n%s", data)
+	}
+}
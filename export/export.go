@@ -0,0 +1,267 @@
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neuralinkcorp/tsui/config"
+	"github.com/neuralinkcorp/tsui/libts"
+	"tailscale.com/ipn/ipnstate"
+)
+
+// A file format to export peers to.
+type Format string
+
+const (
+	// /etc/hosts entries.
+	FormatHosts Format = "hosts"
+	// ~/.ssh/config host blocks.
+	FormatSSHConfig Format = "ssh-config"
+	// Ansible inventory in INI format, with tags as groups.
+	FormatAnsible Format = "ansible"
+	// Spreadsheet with one row per peer.
+	FormatCSV Format = "csv"
+)
+
+// All export formats, in the order they're shown.
+var Formats = []Format{FormatHosts, FormatSSHConfig, FormatAnsible, FormatCSV}
+
+// Parse a format name like "ssh-config".
+func ParseFormat(name string) (Format, error) {
+	if !slices.Contains(Formats, Format(name)) {
+		return "", fmt.Errorf("unknown export format %q", name)
+	}
+	return Format(name), nil
+}
+
+// Get the default name of a file exported in this format, like "tailnet.csv".
+func (f Format) FileName() string {
+	switch f {
+	case FormatHosts:
+		return "tailnet.hosts"
+	case FormatSSHConfig:
+		return "tailnet.ssh_config"
+	case FormatAnsible:
+		return "tailnet-inventory.ini"
+	}
+	return "tailnet." + string(f)
+}
+
+// Create a new file in dir to export to in this format. If the default file name is taken,
+// adds a number like "tailnet-2.csv" rather than overwriting it.
+func (f Format) CreateFile(dir string) (*os.File, error) {
+	name := f.FileName()
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 1; ; i++ {
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+
+		file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return file, err
+	}
+}
+
+// Criteria for which peers to export. Empty fields match every peer.
+type Filter struct {
+	// Login or display name of the owner.
+	Owner string
+	// ACL tag, like "tag:server".
+	Tag string
+	// OS, like "linux".
+	OS string
+	// Whether to leave out offline peers.
+	OnlineOnly bool
+}
+
+// Returns true if a peer matches the filter.
+func (f Filter) Matches(state libts.State, peer *ipnstate.PeerStatus) bool {
+	if f.Owner != "" {
+		user, ok := state.Users[peer.UserID]
+		if peer.IsTagged() || !ok ||
+			(!strings.EqualFold(f.Owner, user.LoginName) && !strings.EqualFold(f.Owner, user.DisplayName)) {
+			return false
+		}
+	}
+	if f.Tag != "" && (!peer.IsTagged() || !peer.Tags.ContainsFunc(func(tag string) bool {
+		return tag == f.Tag
+	})) {
+		return false
+	}
+	if f.OS != "" && !strings.EqualFold(f.OS, peer.OS) {
+		return false
+	}
+	if f.OnlineOnly && !peer.Online {
+		return false
+	}
+	return true
+}
+
+// Get the peers matching a filter, sorted by name.
+func Peers(state libts.State, filter Filter) []*ipnstate.PeerStatus {
+	return slices.DeleteFunc(slices.Clone(state.Peers), func(peer *ipnstate.PeerStatus) bool {
+		return !filter.Matches(state, peer)
+	})
+}
+
+// Write peers in a format. ssh picks the user for SSH config and Ansible entries.
+func Write(w io.Writer, format Format, state libts.State, peers []*ipnstate.PeerStatus, ssh config.SSHConfig) error {
+	var b strings.Builder
+
+	switch format {
+	case FormatHosts:
+		writeHosts(&b, peers)
+	case FormatSSHConfig:
+		writeSSHConfig(&b, peers, ssh)
+	case FormatAnsible:
+		writeAnsible(&b, peers, ssh)
+	case FormatCSV:
+		err := writeCSV(&b, state, peers)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Get a peer's FQDN without the trailing dot.
+func fqdn(peer *ipnstate.PeerStatus) string {
+	return strings.TrimSuffix(peer.DNSName, ".")
+}
+
+// Get a peer's first IPv4 or IPv6 address, or an empty string if it has none.
+func firstIP(peer *ipnstate.PeerStatus, is4 bool) string {
+	for _, addr := range peer.TailscaleIPs {
+		if addr.Is4() == is4 {
+			return addr.String()
+		}
+	}
+	return ""
+}
+
+func writeHosts(b *strings.Builder, peers []*ipnstate.PeerStatus) {
+	b.WriteString("# Tailscale peers, exported by tsui.\n")
+	for _, peer := range peers {
+		names := libts.PeerName(peer)
+		if fqdn := fqdn(peer); fqdn != "" && fqdn != names {
+			names = fqdn + " " + names
+		}
+
+		for _, addr := range peer.TailscaleIPs {
+			fmt.Fprintf(b, "%s\t%s\n", addr, names)
+		}
+	}
+}
+
+func writeSSHConfig(b *strings.Builder, peers []*ipnstate.PeerStatus, ssh config.SSHConfig) {
+	b.WriteString("# Tailscale peers, exported by tsui.\n")
+	for _, peer := range peers {
+		name := libts.PeerName(peer)
+
+		fmt.Fprintf(b, "\nHost %s\n", name)
+		fmt.Fprintf(b, "    HostName %s\n", libts.PeerHost(peer))
+		if user := ssh.UserFor(name, string(peer.ID)); user != "" {
+			fmt.Fprintf(b, "    User %s\n", user)
+		}
+	}
+}
+
+// Turn a tag like "tag:web-prod" into a valid Ansible group name like "tag_web_prod". The
+// prefix keeps tag groups from colliding with the [tailscale] group of all devices.
+func ansibleGroup(tag string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(tag, "tag:"))
+	return "tag_" + name
+}
+
+func writeAnsible(b *strings.Builder, peers []*ipnstate.PeerStatus, ssh config.SSHConfig) {
+	b.WriteString("# Tailscale peers, exported by tsui.\n")
+
+	// Every host goes in the tailscale group with its variables, then each tag becomes a
+	// group listing the hosts with that tag.
+	b.WriteString("[tailscale]\n")
+	groups := make(map[string][]string)
+	groupNames := []string{}
+	for _, peer := range peers {
+		name := libts.PeerName(peer)
+
+		fmt.Fprintf(b, "%s ansible_host=%s", name, libts.PeerHost(peer))
+		if user := ssh.UserFor(name, string(peer.ID)); user != "" {
+			fmt.Fprintf(b, " ansible_user=%s", user)
+		}
+		b.WriteString("\n")
+
+		if !peer.IsTagged() {
+			continue
+		}
+		for _, tag := range peer.Tags.AsSlice() {
+			group := ansibleGroup(tag)
+			if _, ok := groups[group]; !ok {
+				groupNames = append(groupNames, group)
+			}
+			groups[group] = append(groups[group], name)
+		}
+	}
+	slices.Sort(groupNames)
+
+	for _, group := range groupNames {
+		fmt.Fprintf(b, "\n[%s]\n", group)
+		for _, name := range groups[group] {
+			b.WriteString(name + "\n")
+		}
+	}
+}
+
+func writeCSV(b *strings.Builder, state libts.State, peers []*ipnstate.PeerStatus) error {
+	writer := csv.NewWriter(b)
+	writer.Write([]string{"name", "fqdn", "ipv4", "ipv6", "os", "owner", "tags", "online", "last_seen"})
+
+	for _, peer := range peers {
+		tags := ""
+		if peer.IsTagged() {
+			tags = strings.Join(peer.Tags.AsSlice(), " ")
+		}
+
+		lastSeen := ""
+		if !peer.LastSeen.IsZero() {
+			lastSeen = peer.LastSeen.Format(time.RFC3339)
+		}
+
+		writer.Write([]string{
+			libts.PeerName(peer),
+			fqdn(peer),
+			firstIP(peer, true),
+			firstIP(peer, false),
+			peer.OS,
+			state.PeerOwner(peer),
+			tags,
+			strconv.FormatBool(peer.Online),
+			lastSeen,
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
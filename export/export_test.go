@@ -0,0 +1,206 @@
+package export
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neuralinkcorp/tsui/config"
+	"github.com/neuralinkcorp/tsui/libts"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/views"
+)
+
+var (
+	webTags = views.SliceOf([]string{"tag:web-prod", "tag:server"})
+	dbTags  = views.SliceOf([]string{"tag:server"})
+
+	// A personal laptop and two tagged servers, one of them offline, sorted by name like
+	// libts.State.Peers.
+	tailnet = libts.State{
+		Peers: []*ipnstate.PeerStatus{
+			{
+				ID: "n3", DNSName: "db.example.ts.net.", OS: "linux", UserID: 2, Tags: &dbTags,
+				LastSeen:     time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC),
+				TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.3")},
+			},
+			{
+				ID: "n1", DNSName: "laptop.example.ts.net.", OS: "macOS", UserID: 1, Online: true,
+				TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.1"), netip.MustParseAddr("fd7a:115c:a1e0::1")},
+			},
+			{
+				ID: "n2", DNSName: "web.example.ts.net.", OS: "linux", UserID: 2, Tags: &webTags, Online: true,
+				TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.2")},
+			},
+		},
+		Users: map[tailcfg.UserID]tailcfg.UserProfile{
+			1: {LoginName: "alex@example.com", DisplayName: "Alex"},
+			2: {LoginName: "tagged-devices"},
+		},
+	}
+)
+
+func TestPeers(t *testing.T) {
+	tests := []struct {
+		filter Filter
+		want   string
+	}{
+		{Filter{}, "db laptop web"},
+		{Filter{Owner: "alex@example.com"}, "laptop"},
+		// Display names work too, in any case.
+		{Filter{Owner: "alex"}, "laptop"},
+		// Tagged devices aren't owned by anyone.
+		{Filter{Owner: "tagged-devices"}, ""},
+		{Filter{Tag: "tag:server"}, "db web"},
+		{Filter{Tag: "tag:web"}, ""},
+		{Filter{OS: "Linux"}, "db web"},
+		{Filter{OnlineOnly: true}, "laptop web"},
+		{Filter{Tag: "tag:server", OnlineOnly: true}, "web"},
+	}
+
+	for _, test := range tests {
+		var names []string
+		for _, peer := range Peers(tailnet, test.filter) {
+			names = append(names, libts.PeerName(peer))
+		}
+		if got := strings.Join(names, " "); got != test.want {
+			t.Errorf("Peers(%+v) = %q, want %q", test.filter, got, test.want)
+		}
+	}
+
+	if len(tailnet.Peers) != 3 {
+		t.Errorf("Peers modified the state's peers: %+v", tailnet.Peers)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	ssh := config.SSHConfig{
+		DefaultUser: "admin",
+		// By peer name and by ID.
+		Users: map[string]string{"laptop": "alex", "n3": "postgres"},
+	}
+
+	tests := map[Format]string{
+		FormatHosts: `# Tailscale peers, exported by tsui.
+100.64.0.3	db.example.ts.net db
+100.64.0.1	laptop.example.ts.net laptop
+fd7a:115c:a1e0::1	laptop.example.ts.net laptop
+100.64.0.2	web.example.ts.net web
+`,
+		FormatSSHConfig: `# Tailscale peers, exported by tsui.
+
+Host db
+    HostName db.example.ts.net
+    User postgres
+
+Host laptop
+    HostName laptop.example.ts.net
+    User alex
+
+Host web
+    HostName web.example.ts.net
+    User admin
+`,
+		FormatAnsible: `# Tailscale peers, exported by tsui.
+[tailscale]
+db ansible_host=db.example.ts.net ansible_user=postgres
+laptop ansible_host=laptop.example.ts.net ansible_user=alex
+web ansible_host=web.example.ts.net ansible_user=admin
+
+[tag_server]
+db
+web
+
+[tag_web_prod]
+web
+`,
+		FormatCSV: `name,fqdn,ipv4,ipv6,os,owner,tags,online,last_seen
+db,db.example.ts.net,100.64.0.3,,linux,,tag:server,false,2024-07-01T12:00:00Z
+laptop,laptop.example.ts.net,100.64.0.1,fd7a:115c:a1e0::1,macOS,alex@example.com,,true,
+web,web.example.ts.net,100.64.0.2,,linux,,tag:web-prod tag:server,true,
+`,
+	}
+
+	for _, format := range Formats {
+		var b strings.Builder
+		if err := Write(&b, format, tailnet, tailnet.Peers, ssh); err != nil {
+			t.Errorf("%s: %v", format, err)
+			continue
+		}
+		if got := b.String(); got != tests[format] {
+			t.Errorf("%s: got\n%s\nwant\n%s", format, got, tests[format])
+		}
+	}
+
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("ParseFormat accepted an unknown format")
+	}
+	var b strings.Builder
+	if err := Write(&b, "yaml", tailnet, tailnet.Peers, ssh); err == nil || b.Len() != 0 {
+		t.Errorf("unknown format wrote %q with error %v", b.String(), err)
+	}
+}
+
+func TestAnsibleGroup(t *testing.T) {
+	for tag, want := range map[string]string{
+		"tag:server":    "tag_server",
+		"tag:web-prod":  "tag_web_prod",
+		"tag:k8s.node":  "tag_k8s_node",
+		"tag:1password": "tag_1password",
+		// Tag groups never share a name with the group of all devices.
+		"tag:tailscale": "tag_tailscale",
+	} {
+		if got := ansibleGroup(tag); got != want {
+			t.Errorf("ansibleGroup(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestWriteAnsibleTagGroups(t *testing.T) {
+	tags := views.SliceOf([]string{"tag:tailscale"})
+	peers := []*ipnstate.PeerStatus{{ID: "n1", DNSName: "relay.example.ts.net.", Tags: &tags}}
+
+	var b strings.Builder
+	if err := Write(&b, FormatAnsible, tailnet, peers, config.SSHConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# Tailscale peers, exported by tsui.
+[tailscale]
+relay ansible_host=relay.example.ts.net
+
+[tag_tailscale]
+relay
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCreateFile(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "tailnet-inventory.ini"), []byte("[mine]\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"tailnet-inventory-2.ini", "tailnet-inventory-3.ini"} {
+		file, err := FormatAnsible.CreateFile(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+
+		if got := filepath.Base(file.Name()); got != want {
+			t.Errorf("created %s, want %s", got, want)
+		}
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "tailnet-inventory.ini")); string(data) != "[mine]\n" {
+		t.Errorf("existing file was overwritten with %q", data)
+	}
+}
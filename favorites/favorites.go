@@ -0,0 +1,152 @@
+package favorites
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+
+	"tailscale.com/tailcfg"
+)
+
+// Get the directory for tsui's state files, which is $XDG_STATE_HOME/tsui on Linux
+// (defaulting to ~/.local/state/tsui), or the config directory on other platforms.
+func stateDir() (string, error) {
+	if runtime.GOOS == "linux" {
+		if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+			return filepath.Join(dir, "tsui"), nil
+		}
+
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".local", "state", "tsui"), nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tsui"), nil
+}
+
+// The user's favorite peers, persisted to a state file.
+type Favorites struct {
+	// Path of the state file, or an empty string if favorites can't be saved.
+	path string
+	// Stable node IDs of the favorite peers.
+	ids map[tailcfg.StableNodeID]bool
+}
+
+// Load favorites from the state file. If it doesn't exist, there are no favorites yet.
+// Always returns usable favorites: if the file can't be read, they start out empty and the
+// error is returned alongside them.
+func Load() (*Favorites, error) {
+	f := &Favorites{
+		ids: make(map[tailcfg.StableNodeID]bool),
+	}
+
+	dir, err := stateDir()
+	if err != nil {
+		// No state directory means favorites only last for this session, which is fine.
+		return f, nil
+	}
+	f.path = filepath.Join(dir, "favorites.json")
+
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	} else if err != nil {
+		return f, fmt.Errorf("cannot load favorites: %w", err)
+	}
+
+	var ids []tailcfg.StableNodeID
+	err = json.Unmarshal(data, &ids)
+	if err != nil {
+		return f, fmt.Errorf("invalid favorites file %s: %w", f.path, err)
+	}
+
+	for _, id := range ids {
+		f.ids[id] = true
+	}
+
+	return f, nil
+}
+
+// Returns true if the peer with the given ID is a favorite.
+func (f *Favorites) Has(id tailcfg.StableNodeID) bool {
+	return f.ids[id]
+}
+
+// Add or remove a peer from the favorites, and save them.
+func (f *Favorites) Set(id tailcfg.StableNodeID, isFavorite bool) error {
+	if isFavorite {
+		f.ids[id] = true
+	} else {
+		delete(f.ids, id)
+	}
+	return f.save()
+}
+
+// Write the favorites to the state file.
+func (f *Favorites) save() error {
+	if f.path == "" {
+		return nil
+	}
+
+	// Sort so the file doesn't churn.
+	ids := make([]tailcfg.StableNodeID, 0, len(f.ids))
+	for id := range f.ids {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	data, err := json.MarshalIndent(ids, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(f.path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomic(f.path, data)
+	if err != nil {
+		return fmt.Errorf("cannot save favorites: %w", err)
+	}
+	return nil
+}
+
+// Write a file by writing a temporary file next to it and renaming it into place, so a crash
+// or full disk can't leave it half-written.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// Clean up if anything fails. After the rename, this fails harmlessly.
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
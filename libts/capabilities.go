@@ -0,0 +1,76 @@
+package libts
+
+import (
+	"fmt"
+)
+
+// An optional Tailscale feature that only newer daemons support.
+type Feature string
+
+const (
+	FeatureTaildrop          Feature = "Taildrop"
+	FeatureSSH               Feature = "Tailscale SSH"
+	FeatureProfiles          Feature = "Profiles"
+	FeatureServe             Feature = "Serve"
+	FeatureAutoUpdate        Feature = "Auto-updates"
+	FeatureWebClient         Feature = "Web interface"
+	FeaturePostureChecking   Feature = "Posture checking"
+	FeatureAppConnector      Feature = "App connectors"
+	FeatureStatefulFiltering Feature = "Stateful filtering"
+)
+
+// The first daemon version supporting each feature.
+var featureMinVersions = map[Feature]string{
+	FeatureTaildrop:          "1.8.0",
+	FeatureSSH:               "1.24.0",
+	FeatureProfiles:          "1.34.0",
+	FeatureServe:             "1.36.0",
+	FeatureAutoUpdate:        "1.52.0",
+	FeatureWebClient:         "1.56.0",
+	FeaturePostureChecking:   "1.56.0",
+	FeatureAppConnector:      "1.58.0",
+	FeatureStatefulFiltering: "1.66.0",
+}
+
+// Get the first daemon version that supports a feature, like "1.56.0".
+func (f Feature) MinVersion() string {
+	return featureMinVersions[f]
+}
+
+// The set of features supported by the Tailscale daemon.
+//
+// tsui is built against a single version of the Tailscale client library, but talks to
+// whatever daemon is installed, which may be older and not understand newer prefs or APIs.
+type Capabilities struct {
+	// Daemon version like "1.70.0", or an empty string if unknown.
+	Version string
+	// Features supported by the daemon.
+	supported map[Feature]bool
+}
+
+// Determine the features supported by a daemon, given its version.
+func NewCapabilities(version string) Capabilities {
+	caps := Capabilities{
+		Version:   version,
+		supported: make(map[Feature]bool, len(featureMinVersions)),
+	}
+
+	for feature, minVersion := range featureMinVersions {
+		caps.supported[feature] = VersionAtLeast(version, minVersion)
+	}
+
+	return caps
+}
+
+// Returns true if the daemon supports a feature.
+func (c Capabilities) Supports(f Feature) bool {
+	return c.supported[f]
+}
+
+// Returns a user-friendly error if the daemon doesn't support a feature, or nil if it does.
+func (c Capabilities) Require(f Feature) error {
+	if c.Supports(f) {
+		return nil
+	}
+	return fmt.Errorf("%s requires Tailscale %s or newer, but this device has %s", f, f.MinVersion(), c.Version)
+}
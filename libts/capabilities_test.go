@@ -0,0 +1,61 @@
+package libts
+
+import (
+	"testing"
+)
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version    string
+		minVersion string
+		want       bool
+	}{
+		{"1.70.0", "1.56.0", true},
+		{"1.56.0", "1.56.0", true},
+		{"1.54.1", "1.56.0", false},
+		// Compared numerically, not as strings.
+		{"1.8.0", "1.24.0", false},
+		{"1.100.0", "1.58.0", true},
+		// Unknown versions are assumed to be new enough.
+		{"", "1.66.0", true},
+	}
+
+	for _, test := range tests {
+		if got := VersionAtLeast(test.version, test.minVersion); got != test.want {
+			t.Errorf("VersionAtLeast(%q, %q) = %v, want %v", test.version, test.minVersion, got, test.want)
+		}
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	caps := NewCapabilities("1.56.0")
+
+	for _, feature := range []Feature{FeatureSSH, FeatureProfiles, FeatureWebClient, FeaturePostureChecking} {
+		if !caps.Supports(feature) {
+			t.Errorf("1.56.0 should support %s", feature)
+		}
+		if err := caps.Require(feature); err != nil {
+			t.Errorf("Require(%s) = %v, want nil", feature, err)
+		}
+	}
+
+	for _, feature := range []Feature{FeatureAppConnector, FeatureStatefulFiltering} {
+		if caps.Supports(feature) {
+			t.Errorf("1.56.0 shouldn't support %s", feature)
+		}
+	}
+
+	want := "App connectors requires Tailscale 1.58.0 or newer, but this device has 1.56.0"
+	if err := caps.Require(FeatureAppConnector); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestCapabilitiesUnknownVersion(t *testing.T) {
+	caps := NewCapabilities("")
+	for feature := range featureMinVersions {
+		if !caps.Supports(feature) {
+			t.Errorf("unknown version should be assumed to support %s", feature)
+		}
+	}
+}
@@ -2,21 +2,60 @@ package libts
 
 import (
 	"context"
+	"errors"
 	"runtime"
+	"strings"
 
 	"tailscale.com/client/tailscale"
 	"tailscale.com/ipn"
 	"tailscale.com/ipn/ipnstate"
 	"tailscale.com/tailcfg"
+	"tailscale.com/types/netmap"
 )
 
 var ts tailscale.LocalClient
 
+// Connect to tailscaled through the socket at path instead of the platform default, such as
+// for userspace-networking instances and containers. Must be called before any other calls.
+func SetSocket(path string) {
+	ts.Socket = path
+	ts.UseSocketOnly = true
+}
+
+// Get the tailscaled socket path set with SetSocket, or an empty string if using the default.
+func Socket() string {
+	return ts.Socket
+}
+
 // Return the Tailscale daemon status. Returns an error if the daemon is not running.
 func Status(ctx context.Context) (*ipnstate.Status, error) {
 	return ts.Status(ctx)
 }
 
+// Start watching the IPN bus, returning the watcher and the current network map, which the
+// LocalAPI only exposes this way. The watcher must be closed when done.
+//
+// Private keys are left out of the network map. tsui doesn't need them, and tailscaled only
+// lets root and operators watch the bus with them.
+func watchNetMap(ctx context.Context) (*tailscale.IPNBusWatcher, *netmap.NetworkMap, error) {
+	watcher, err := ts.WatchIPNBus(ctx, ipn.NotifyInitialNetMap|ipn.NotifyNoPrivateKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notify, err := watcher.Next()
+	if err != nil {
+		watcher.Close()
+		return nil, nil, err
+	}
+	if notify.NetMap == nil {
+		watcher.Close()
+		return nil, nil, errors.New("no network map available; is Tailscale connected?")
+	}
+
+	return watcher, notify.NetMap, nil
+}
+
 // Returns true if StartLoginInteractive will (probably) open the user's web browser.
 // Can be used to decide whether to display UI elements related to interactive login.
 func StartLoginInteractiveWillOpenBrowser() bool {
@@ -38,6 +77,21 @@ func StartLoginInteractive(ctx context.Context) error {
 	return ts.StartLoginInteractive(ctx)
 }
 
+// Get the URL of the admin console's machines page, where admins approve devices.
+// Accounts for custom control servers.
+func AdminMachinesURL(prefs *ipn.Prefs) string {
+	controlURL := ipn.DefaultControlURL
+	if prefs != nil {
+		controlURL = prefs.ControlURLOrDefault()
+	}
+
+	// Tailscale's own control server has its admin console on a different domain.
+	if controlURL == ipn.DefaultControlURL || ipn.IsLoginServerSynonym(controlURL) {
+		return "https://login.tailscale.com/admin/machines"
+	}
+	return strings.TrimSuffix(controlURL, "/") + "/admin/machines"
+}
+
 // Ping a peer.
 func PingPeer(ctx context.Context, peer *ipnstate.PeerStatus) (*ipnstate.PingResult, error) {
 	// Discovery ping is the most reliable because it doesn't rely on the host accepting ICMP or anything.
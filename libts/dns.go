@@ -0,0 +1,98 @@
+package libts
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+
+	"tailscale.com/types/dnstype"
+)
+
+// Address of Tailscale's built-in DNS resolver, which is reachable from every node.
+const quad100Resolver = "100.100.100.100:53"
+
+// A split DNS route, sending queries for a domain to specific nameservers.
+type SplitDNSRoute struct {
+	// Domain suffix the route applies to.
+	Domain string
+	// Addresses of the nameservers. Empty if queries for this domain are sent to the
+	// default nameservers, which is how tailnets route their own MagicDNS domain.
+	Nameservers []string
+}
+
+// DNS configuration pushed to this node by the tailnet.
+type DNSStatus struct {
+	// Whether MagicDNS is enabled for the tailnet.
+	MagicDNSEnabled bool
+	// The tailnet's MagicDNS suffix like "tail1234.ts.net".
+	MagicDNSSuffix string
+
+	// Addresses of the global nameservers.
+	Nameservers []string
+	// Split DNS routes sorted by domain.
+	SplitDNSRoutes []SplitDNSRoute
+	// Search domains.
+	SearchDomains []string
+}
+
+// Get the addresses of a list of DNS resolvers.
+func resolverAddrs(resolvers []*dnstype.Resolver) []string {
+	addrs := make([]string, 0, len(resolvers))
+	for _, resolver := range resolvers {
+		addrs = append(addrs, resolver.Addr)
+	}
+	return addrs
+}
+
+// Get the current DNS configuration from the network map.
+func GetDNSStatus(ctx context.Context) (*DNSStatus, error) {
+	status, err := ts.StatusWithoutPeers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, netMap, err := watchNetMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	watcher.Close()
+
+	dnsConfig := netMap.DNS
+	dnsStatus := &DNSStatus{
+		Nameservers:   resolverAddrs(dnsConfig.Resolvers),
+		SearchDomains: dnsConfig.Domains,
+	}
+
+	if status.CurrentTailnet != nil {
+		dnsStatus.MagicDNSEnabled = status.CurrentTailnet.MagicDNSEnabled
+		dnsStatus.MagicDNSSuffix = status.CurrentTailnet.MagicDNSSuffix
+	}
+
+	for domain, resolvers := range dnsConfig.Routes {
+		dnsStatus.SplitDNSRoutes = append(dnsStatus.SplitDNSRoutes, SplitDNSRoute{
+			Domain:      domain,
+			Nameservers: resolverAddrs(resolvers),
+		})
+	}
+	slices.SortFunc(dnsStatus.SplitDNSRoutes, func(a, b SplitDNSRoute) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+
+	return dnsStatus, nil
+}
+
+// Resolve a name through Tailscale's DNS resolver, returning its addresses.
+// Only works while Tailscale is connected, and the query goes through this computer's network,
+// so it doesn't test a daemon set with SetSocket.
+func QueryDNS(ctx context.Context, name string) ([]string, error) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, quad100Resolver)
+		},
+	}
+
+	return resolver.LookupHost(ctx, name)
+}
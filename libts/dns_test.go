@@ -0,0 +1,106 @@
+package libts
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"tailscale.com/client/tailscale"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/dnstype"
+	"tailscale.com/types/netmap"
+)
+
+// Point the LocalAPI client at a fake tailscaled for the rest of the test.
+func fakeLocalAPI(t *testing.T, handler http.Handler) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	ts = tailscale.LocalClient{
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", server.Listener.Addr().String())
+		},
+	}
+	t.Cleanup(func() { ts = tailscale.LocalClient{} })
+}
+
+func TestGetDNSStatus(t *testing.T) {
+	var mask ipn.NotifyWatchOpt
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/localapi/v0/status", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&ipnstate.Status{
+			CurrentTailnet: &ipnstate.TailnetStatus{MagicDNSEnabled: true, MagicDNSSuffix: "tail1234.ts.net"},
+		})
+	})
+	mux.HandleFunc("/localapi/v0/watch-ipn-bus", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.ParseUint(r.URL.Query().Get("mask"), 10, 64)
+		mask = ipn.NotifyWatchOpt(n)
+
+		json.NewEncoder(w).Encode(&ipn.Notify{
+			NetMap: &netmap.NetworkMap{
+				DNS: tailcfg.DNSConfig{
+					Resolvers: []*dnstype.Resolver{{Addr: "1.1.1.1"}},
+					Routes: map[string][]*dnstype.Resolver{
+						"corp.example.com": {{Addr: "10.0.0.53"}},
+						"tail1234.ts.net":  nil,
+					},
+					Domains: []string{"tail1234.ts.net"},
+				},
+			},
+		})
+	})
+	fakeLocalAPI(t, mux)
+
+	status, err := GetDNSStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only root and operators may watch the bus with private keys, so asking for them would
+	// fail for everyone else.
+	if want := ipn.NotifyInitialNetMap | ipn.NotifyNoPrivateKeys; mask != want {
+		t.Errorf("watched the IPN bus with mask %v, want %v", mask, want)
+	}
+
+	if !status.MagicDNSEnabled || status.MagicDNSSuffix != "tail1234.ts.net" {
+		t.Errorf("got MagicDNS %v %q", status.MagicDNSEnabled, status.MagicDNSSuffix)
+	}
+	if !slices.Equal(status.Nameservers, []string{"1.1.1.1"}) {
+		t.Errorf("got nameservers %q", status.Nameservers)
+	}
+	wantRoutes := []SplitDNSRoute{
+		{Domain: "corp.example.com", Nameservers: []string{"10.0.0.53"}},
+		{Domain: "tail1234.ts.net", Nameservers: []string{}},
+	}
+	if !slices.EqualFunc(status.SplitDNSRoutes, wantRoutes, func(a, b SplitDNSRoute) bool {
+		return a.Domain == b.Domain && slices.Equal(a.Nameservers, b.Nameservers)
+	}) {
+		t.Errorf("got split DNS routes %+v, want %+v", status.SplitDNSRoutes, wantRoutes)
+	}
+}
+
+func TestGetDNSStatusNoNetMap(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/localapi/v0/status", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&ipnstate.Status{})
+	})
+	mux.HandleFunc("/localapi/v0/watch-ipn-bus", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&ipn.Notify{State: new(ipn.State)})
+	})
+	fakeLocalAPI(t, mux)
+
+	if _, err := GetDNSStatus(context.Background()); err == nil {
+		t.Error("got no error without a network map")
+	}
+}
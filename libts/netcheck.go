@@ -0,0 +1,224 @@
+package libts
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/opt"
+)
+
+// Latency to a DERP relay region.
+type DERPRegionLatency struct {
+	// Short region code like "fra".
+	Code string
+	// Human-readable region name like "Frankfurt".
+	Name string
+	// Round trip latency, or 0 if the region didn't respond.
+	Latency time.Duration
+	// True if this is the region the node prefers, which is usually the one with the lowest
+	// latency.
+	IsPreferred bool
+}
+
+// Opinionated summary of tailscaled's network connectivity check, like `tailscale netcheck`.
+type NetcheckReport struct {
+	// Time the report was received from tailscaled.
+	Time time.Time
+
+	// True if a UDP round trip completed. Without UDP, all traffic is relayed over DERP.
+	UDP bool
+	// True if an IPv4 round trip completed.
+	IPv4 bool
+	// True if an IPv6 round trip completed.
+	IPv6 bool
+	// True if the OS supports IPv6 at all.
+	OSHasIPv6 bool
+
+	// Whether the public address depends on which server we talk to. This is the signature of
+	// a "hard" NAT, which makes direct connections difficult.
+	MappingVariesByDestIP opt.Bool
+	// Port mapping protocols available on the LAN, like "UPnP", or empty if none were found.
+	PortMapping []string
+
+	// DERP region latencies sorted fastest first, with unreachable regions last.
+	DERPLatencies []DERPRegionLatency
+}
+
+// Get tailscaled's network connectivity check, equivalent to `tailscale netcheck`.
+//
+// The LocalAPI doesn't expose netcheck, but tailscaled runs one whenever the network changes
+// and reports it to the control server, which sends it back in the node's own entry of the
+// network map. This asks tailscaled to rerun its probes and waits until ctx is done for the
+// new result, falling back to the previous one. Rerunning needs write access, so without it
+// the previous result is returned right away.
+func Netcheck(ctx context.Context) (*NetcheckReport, error) {
+	// Start watching before asking for the rerun so we can't miss the new result.
+	watcher, netMap, err := watchNetMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer watcher.Close()
+
+	lastNetInfo := netMap.SelfNode.Hostinfo().NetInfo().AsStruct()
+	report, reportErr := newNetcheckReport(lastNetInfo, netMap.DERPMap, time.Now())
+	if err := ts.DebugAction(ctx, "restun"); err != nil {
+		return report, reportErr
+	}
+
+	for {
+		notify, err := watcher.Next()
+		if err != nil {
+			// No new result before ctx was done.
+			return report, reportErr
+		}
+		if notify.NetMap == nil {
+			continue
+		}
+
+		netInfo := notify.NetMap.SelfNode.Hostinfo().NetInfo().AsStruct()
+		if netInfo == nil || (netInfo.BasicallyEqual(lastNetInfo) && maps.Equal(netInfo.DERPLatency, lastNetInfo.DERPLatency)) {
+			continue
+		}
+		return newNetcheckReport(netInfo, notify.NetMap.DERPMap, time.Now())
+	}
+}
+
+// Summarize a netcheck result from tailscaled, naming its DERP regions using derpMap.
+func newNetcheckReport(netInfo *tailcfg.NetInfo, derpMap *tailcfg.DERPMap, now time.Time) (*NetcheckReport, error) {
+	if netInfo == nil || derpMap == nil {
+		return nil, errors.New("tailscaled hasn't reported a netcheck yet; try again once connected")
+	}
+
+	report := &NetcheckReport{
+		Time:                  now,
+		UDP:                   netInfo.WorkingUDP.EqualBool(true),
+		IPv6:                  netInfo.WorkingIPv6.EqualBool(true),
+		OSHasIPv6:             netInfo.OSHasIPv6.EqualBool(true),
+		MappingVariesByDestIP: netInfo.MappingVariesByDestIP,
+	}
+
+	if netInfo.UPnP.EqualBool(true) {
+		report.PortMapping = append(report.PortMapping, "UPnP")
+	}
+	if netInfo.PMP.EqualBool(true) {
+		report.PortMapping = append(report.PortMapping, "NAT-PMP")
+	}
+	if netInfo.PCP.EqualBool(true) {
+		report.PortMapping = append(report.PortMapping, "PCP")
+	}
+
+	// Latencies are keyed like "1-v4" and "1-v6" for region 1, in seconds. A region's latency
+	// is its fastest over either address family.
+	latencies := make(map[int]time.Duration)
+	for key, seconds := range netInfo.DERPLatency {
+		id, family, ok := strings.Cut(key, "-")
+		regionID, err := strconv.Atoi(id)
+		if !ok || err != nil || seconds <= 0 {
+			continue
+		}
+		if family == "v4" {
+			report.IPv4 = true
+		}
+
+		latency := time.Duration(seconds * float64(time.Second))
+		if current, ok := latencies[regionID]; !ok || latency < current {
+			latencies[regionID] = latency
+		}
+	}
+
+	for id, region := range derpMap.Regions {
+		report.DERPLatencies = append(report.DERPLatencies, DERPRegionLatency{
+			Code:        region.RegionCode,
+			Name:        region.RegionName,
+			Latency:     latencies[id],
+			IsPreferred: id == netInfo.PreferredDERP,
+		})
+	}
+	slices.SortFunc(report.DERPLatencies, func(a, b DERPRegionLatency) int {
+		// Unreachable regions go last.
+		if (a.Latency == 0) != (b.Latency == 0) {
+			if a.Latency == 0 {
+				return 1
+			}
+			return -1
+		}
+		if a.Latency != b.Latency {
+			return cmp.Compare(a.Latency, b.Latency)
+		}
+		return strings.Compare(a.Code, b.Code)
+	})
+
+	return report, nil
+}
+
+// Returns the preferred DERP region, or nil if none could be reached.
+func (r *NetcheckReport) PreferredDERP() *DERPRegionLatency {
+	for i := range r.DERPLatencies {
+		if r.DERPLatencies[i].IsPreferred {
+			return &r.DERPLatencies[i]
+		}
+	}
+	return nil
+}
+
+// Format an opt.Bool as "yes", "no", or "unknown".
+func formatOptBool(b opt.Bool) string {
+	v, ok := b.Get()
+	if !ok {
+		return "unknown"
+	} else if v {
+		return "yes"
+	}
+	return "no"
+}
+
+// Format the report as plain text, suitable for pasting into a bug report.
+func (r *NetcheckReport) String() string {
+	var s strings.Builder
+
+	fmt.Fprintf(&s, "Netcheck report (%s):\n", r.Time.Format(time.RFC3339))
+	fmt.Fprintf(&s, "\t* UDP: %v\n", r.UDP)
+
+	fmt.Fprintf(&s, "\t* IPv4: %v\n", r.IPv4)
+
+	if r.IPv6 {
+		fmt.Fprintf(&s, "\t* IPv6: yes\n")
+	} else if r.OSHasIPv6 {
+		fmt.Fprintf(&s, "\t* IPv6: no, but OS has support\n")
+	} else {
+		fmt.Fprintf(&s, "\t* IPv6: no, unavailable in OS\n")
+	}
+
+	fmt.Fprintf(&s, "\t* MappingVariesByDestIP: %s\n", formatOptBool(r.MappingVariesByDestIP))
+
+	if len(r.PortMapping) == 0 {
+		fmt.Fprintf(&s, "\t* PortMapping: none\n")
+	} else {
+		fmt.Fprintf(&s, "\t* PortMapping: %s\n", strings.Join(r.PortMapping, ", "))
+	}
+
+	if preferred := r.PreferredDERP(); preferred != nil {
+		fmt.Fprintf(&s, "\t* Nearest DERP: %s\n", preferred.Name)
+	} else {
+		fmt.Fprintf(&s, "\t* Nearest DERP: unknown (no response to latency probes)\n")
+	}
+
+	fmt.Fprintf(&s, "\t* DERP latency:\n")
+	for _, region := range r.DERPLatencies {
+		var latency string
+		if region.Latency != 0 {
+			latency = region.Latency.Round(time.Millisecond / 10).String()
+		}
+		fmt.Fprintf(&s, "\t\t- %3s: %-7s (%s)\n", region.Code, latency, region.Name)
+	}
+
+	return s.String()
+}
@@ -0,0 +1,159 @@
+package libts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"tailscale.com/ipn"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/netmap"
+	"tailscale.com/types/opt"
+)
+
+var derpMap = &tailcfg.DERPMap{
+	Regions: map[int]*tailcfg.DERPRegion{
+		1: {RegionID: 1, RegionCode: "nyc", RegionName: "New York City"},
+		2: {RegionID: 2, RegionCode: "sfo", RegionName: "San Francisco"},
+		4: {RegionID: 4, RegionCode: "fra", RegionName: "Frankfurt"},
+	},
+}
+
+// Network map whose own node reports the given netcheck result.
+func netMapWithNetInfo(netInfo *tailcfg.NetInfo) *netmap.NetworkMap {
+	hostinfo := &tailcfg.Hostinfo{NetInfo: netInfo}
+	return &netmap.NetworkMap{
+		SelfNode: (&tailcfg.Node{Hostinfo: hostinfo.View()}).View(),
+		DERPMap:  derpMap,
+	}
+}
+
+func TestNewNetcheckReport(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	report, err := newNetcheckReport(&tailcfg.NetInfo{
+		MappingVariesByDestIP: opt.NewBool(false),
+		WorkingIPv6:           opt.NewBool(false),
+		OSHasIPv6:             opt.NewBool(true),
+		WorkingUDP:            opt.NewBool(true),
+		UPnP:                  opt.NewBool(true),
+		PMP:                   opt.NewBool(false),
+		PreferredDERP:         2,
+		// The fastest of each region's IPv4 and IPv6 latencies counts.
+		DERPLatency: map[string]float64{
+			"1-v4": 0.08,
+			"2-v4": 0.012,
+			"2-v6": 0.0105,
+		},
+	}, derpMap, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Time.Equal(now) || !report.UDP || !report.IPv4 || report.IPv6 || !report.OSHasIPv6 {
+		t.Errorf("got %+v", report)
+	}
+	if v, ok := report.MappingVariesByDestIP.Get(); !ok || v {
+		t.Errorf("got MappingVariesByDestIP %q, want false", report.MappingVariesByDestIP)
+	}
+	if len(report.PortMapping) != 1 || report.PortMapping[0] != "UPnP" {
+		t.Errorf("got port mapping %q, want UPnP", report.PortMapping)
+	}
+
+	want := []DERPRegionLatency{
+		{Code: "sfo", Name: "San Francisco", Latency: 10500 * time.Microsecond, IsPreferred: true},
+		{Code: "nyc", Name: "New York City", Latency: 80 * time.Millisecond},
+		// Unreachable regions go last.
+		{Code: "fra", Name: "Frankfurt"},
+	}
+	if len(report.DERPLatencies) != len(want) {
+		t.Fatalf("got DERP latencies %+v, want %+v", report.DERPLatencies, want)
+	}
+	for i := range want {
+		if report.DERPLatencies[i] != want[i] {
+			t.Errorf("got DERP latency %+v, want %+v", report.DERPLatencies[i], want[i])
+		}
+	}
+	if preferred := report.PreferredDERP(); preferred == nil || preferred.Code != "sfo" {
+		t.Errorf("got preferred DERP %+v, want sfo", preferred)
+	}
+
+	if _, err := newNetcheckReport(nil, derpMap, now); err == nil {
+		t.Error("got no error before tailscaled reported a netcheck")
+	}
+}
+
+func TestNetcheck(t *testing.T) {
+	restun := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/localapi/v0/watch-ipn-bus", func(w http.ResponseWriter, r *http.Request) {
+		encoder := json.NewEncoder(w)
+		encoder.Encode(&ipn.Notify{NetMap: netMapWithNetInfo(&tailcfg.NetInfo{
+			WorkingUDP:  opt.NewBool(false),
+			DERPLatency: map[string]float64{"1-v4": 0.08},
+		})})
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-restun:
+		case <-r.Context().Done():
+			return
+		}
+
+		// Unrelated updates don't end the wait.
+		encoder.Encode(&ipn.Notify{State: new(ipn.State)})
+		encoder.Encode(&ipn.Notify{NetMap: netMapWithNetInfo(&tailcfg.NetInfo{
+			WorkingUDP:    opt.NewBool(true),
+			PreferredDERP: 1,
+			DERPLatency:   map[string]float64{"1-v4": 0.02},
+		})})
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/localapi/v0/debug", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Query().Get("action") != "restun" {
+			http.Error(w, "unexpected debug action", http.StatusBadRequest)
+			return
+		}
+		close(restun)
+	})
+	fakeLocalAPI(t, mux)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	report, err := Netcheck(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.UDP || report.DERPLatencies[0].Latency != 20*time.Millisecond {
+		t.Errorf("got %+v, want the result after the rerun", report)
+	}
+}
+
+func TestNetcheckWithoutWriteAccess(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/localapi/v0/watch-ipn-bus", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&ipn.Notify{NetMap: netMapWithNetInfo(&tailcfg.NetInfo{
+			WorkingUDP:  opt.NewBool(true),
+			DERPLatency: map[string]float64{"1-v4": 0.08},
+		})})
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/localapi/v0/debug", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "debug access denied", http.StatusForbidden)
+	})
+	fakeLocalAPI(t, mux)
+
+	// The previous result comes back right away instead of waiting for one that won't come.
+	report, err := Netcheck(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.UDP || report.DERPLatencies[0].Latency != 80*time.Millisecond {
+		t.Errorf("got %+v, want the previous result", report)
+	}
+}
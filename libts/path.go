@@ -0,0 +1,52 @@
+package libts
+
+import (
+	"tailscale.com/ipn/ipnstate"
+)
+
+// The route traffic takes to reach a peer.
+type PeerPath struct {
+	// True if traffic flows directly to the peer over UDP.
+	IsDirect bool
+	// True if traffic is relayed through a DERP server.
+	IsRelayed bool
+	// The peer's ip:port, if direct.
+	Endpoint string
+	// The DERP region code like "fra", if relayed.
+	DERPRegion string
+}
+
+// Determine the connection path to a peer. If ping is non-nil and successful, it's used
+// as the most up-to-date source. Otherwise, the path is taken from the peer status, which
+// only knows the path of peers we've recently exchanged traffic with.
+//
+// If neither is available, the path is unknown and both IsDirect and IsRelayed are false.
+func GetPeerPath(peer *ipnstate.PeerStatus, ping *ipnstate.PingResult) PeerPath {
+	if ping != nil && ping.Err == "" {
+		if ping.Endpoint != "" {
+			return PeerPath{IsDirect: true, Endpoint: ping.Endpoint}
+		} else if ping.DERPRegionCode != "" {
+			return PeerPath{IsRelayed: true, DERPRegion: ping.DERPRegionCode}
+		}
+	}
+
+	if peer.CurAddr != "" {
+		return PeerPath{IsDirect: true, Endpoint: peer.CurAddr}
+	} else if peer.Active && peer.Relay != "" {
+		// Active with no direct address means we're talking through the peer's home DERP.
+		return PeerPath{IsRelayed: true, DERPRegion: peer.Relay}
+	}
+
+	return PeerPath{}
+}
+
+// Short description of the path like "direct 203.0.113.7:41641" or "DERP fra", or an empty
+// string if unknown.
+func (p PeerPath) String() string {
+	if p.IsDirect {
+		return "direct " + p.Endpoint
+	} else if p.IsRelayed {
+		return "DERP " + p.DERPRegion
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package libts
+
+import (
+	"testing"
+
+	"tailscale.com/ipn/ipnstate"
+)
+
+func TestGetPeerPath(t *testing.T) {
+	tests := []struct {
+		name string
+		peer ipnstate.PeerStatus
+		ping *ipnstate.PingResult
+		want string
+	}{
+		{
+			name: "unknown",
+			peer: ipnstate.PeerStatus{Relay: "fra"},
+			want: "",
+		},
+		{
+			name: "direct from status",
+			peer: ipnstate.PeerStatus{CurAddr: "203.0.113.7:41641", Relay: "fra", Active: true},
+			want: "direct 203.0.113.7:41641",
+		},
+		{
+			name: "relayed from status",
+			peer: ipnstate.PeerStatus{Relay: "fra", Active: true},
+			want: "DERP fra",
+		},
+		{
+			name: "ping overrides status",
+			peer: ipnstate.PeerStatus{CurAddr: "203.0.113.7:41641", Active: true},
+			ping: &ipnstate.PingResult{DERPRegionCode: "nyc"},
+			want: "DERP nyc",
+		},
+		{
+			name: "direct ping",
+			peer: ipnstate.PeerStatus{Relay: "fra", Active: true},
+			ping: &ipnstate.PingResult{Endpoint: "198.51.100.2:41641", DERPRegionCode: "fra"},
+			want: "direct 198.51.100.2:41641",
+		},
+		{
+			name: "failed ping falls back to status",
+			peer: ipnstate.PeerStatus{Relay: "fra", Active: true},
+			ping: &ipnstate.PingResult{Err: "timeout", DERPRegionCode: "nyc"},
+			want: "DERP fra",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := GetPeerPath(&test.peer, test.ping)
+			if got := path.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if path.IsDirect && path.IsRelayed {
+				t.Errorf("path %+v is both direct and relayed", path)
+			}
+		})
+	}
+}
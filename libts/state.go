@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 
 	"tailscale.com/ipn"
 	"tailscale.com/ipn/ipnstate"
@@ -12,6 +13,10 @@ import (
 	"tailscale.com/types/key"
 )
 
+// How long to wait for the daemon to report its state when checking if another user is
+// using it.
+const inUseCheckTimeout = 2 * time.Second
+
 // Opinionated, sanitized subset of Tailscale state.
 type State struct {
 	// Tailscale preferences.
@@ -22,11 +27,21 @@ type State struct {
 
 	// Current Tailscale version. This is a shortened version string like "1.70.0".
 	TSVersion string
+	// Features supported by the Tailscale daemon, based on TSVersion.
+	Capabilities Capabilities
 
 	// Auth URL. Empty if the user doesn't need to be authenticated.
 	AuthURL string
+	// Explanation from Tailscale of who is using it, if BackendState is InUseOtherUser.
+	// Something like "Tailscale already in use by DESKTOP\\bob, pid 1234".
+	InUseMessage string
+	// Name of the OS user using Tailscale, if BackendState is InUseOtherUser and Tailscale
+	// reported it.
+	InUseBy string
 	// User profile of the currently logged in user or nil if unknown.
 	User *tailcfg.UserProfile
+	// User profiles of everyone who owns a visible node, keyed by user ID.
+	Users map[tailcfg.UserID]tailcfg.UserProfile
 
 	// Peer status of the local node.
 	Self *ipnstate.PeerStatus
@@ -36,6 +51,8 @@ type State struct {
 	// True if the node is locked out by tailnet lock.
 	IsLockedOut bool
 
+	// All peers sorted by PeerName.
+	Peers []*ipnstate.PeerStatus
 	// Exit node peers sorted by PeerName.
 	ExitNodes []*ipnstate.PeerStatus
 	// Peers owned by the user sorted by PeerName.
@@ -94,6 +111,15 @@ func NewIPNStateFromString(v string) (ipn.State, error) {
 func GetState(ctx context.Context) (State, error) {
 	status, err := Status(ctx)
 	if err != nil {
+		if isInUseError(err) {
+			if message, ok := inUseOtherUser(ctx); ok {
+				return State{
+					BackendState: ipn.InUseOtherUser,
+					InUseMessage: message,
+					InUseBy:      parseInUseBy(message),
+				}, nil
+			}
+		}
 		return State{}, err
 	}
 
@@ -118,6 +144,7 @@ func GetState(ctx context.Context) (State, error) {
 		BackendState: backendState,
 		TSVersion:    status.Version,
 		Self:         status.Self,
+		Users:        status.User,
 		OwnedNodes:   make(map[string][]*ipnstate.PeerStatus),
 	}
 
@@ -125,6 +152,8 @@ func GetState(ctx context.Context) (State, error) {
 		state.TxBytes += peer.TxBytes
 		state.RxBytes += peer.RxBytes
 
+		state.Peers = append(state.Peers, peer)
+
 		if peer.ExitNodeOption {
 			state.ExitNodes = append(state.ExitNodes, peer)
 		}
@@ -149,6 +178,7 @@ func GetState(ctx context.Context) (State, error) {
 		}
 	}
 
+	sortNodes(state.Peers)
 	sortNodes(state.ExitNodes)
 	sortNodes(state.MyNodes)
 	sortNodes(state.TaggedNodes)
@@ -162,6 +192,7 @@ func GetState(ctx context.Context) (State, error) {
 	if versionSplitIndex != -1 {
 		state.TSVersion = state.TSVersion[:versionSplitIndex]
 	}
+	state.Capabilities = NewCapabilities(state.TSVersion)
 
 	if status.Self != nil {
 		user := status.User[status.Self.UserID]
@@ -189,3 +220,56 @@ func GetState(ctx context.Context) (State, error) {
 
 	return state, nil
 }
+
+// Get the login name of the user who owns a peer, or an empty string if the peer is tagged
+// or the owner is unknown.
+func (s State) PeerOwner(peer *ipnstate.PeerStatus) string {
+	if peer.IsTagged() {
+		return ""
+	}
+	return s.Users[peer.UserID].LoginName
+}
+
+// Check if a LocalAPI error is tailscaled refusing the request because another OS user is
+// using Tailscale. There's no error type or status code for this, only the message.
+func isInUseError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), " already in use by ")
+}
+
+// Check if another OS user is using Tailscale, returning Tailscale's explanation if so.
+//
+// This mostly happens on Windows, where only one user can control Tailscale at a time.
+// The LocalAPI then refuses every request except watching the IPN bus, which reports the
+// InUseOtherUser state.
+func inUseOtherUser(ctx context.Context) (string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, inUseCheckTimeout)
+	defer cancel()
+
+	watcher, err := ts.WatchIPNBus(ctx, ipn.NotifyInitialState|ipn.NotifyNoPrivateKeys)
+	if err != nil {
+		return "", false
+	}
+	defer watcher.Close()
+
+	notify, err := watcher.Next()
+	if err != nil || notify.State == nil || *notify.State != ipn.InUseOtherUser {
+		return "", false
+	}
+
+	if notify.ErrMessage == nil {
+		return "", true
+	}
+	return *notify.ErrMessage, true
+}
+
+// Extract the username from an InUseOtherUser message like
+// "Tailscale already in use by DESKTOP\\bob, pid 1234". Returns an empty string if it
+// isn't there.
+func parseInUseBy(message string) string {
+	_, after, ok := strings.Cut(message, " in use by ")
+	if !ok {
+		return ""
+	}
+	user, _, _ := strings.Cut(after, ", pid ")
+	return user
+}
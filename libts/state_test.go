@@ -0,0 +1,64 @@
+package libts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"tailscale.com/ipn"
+	"tailscale.com/types/ptr"
+)
+
+// Message tailscaled refuses LocalAPI requests with while another OS user is using it.
+const inUseMessage = `Tailscale already in use by DESKTOP\bob, pid 1234`
+
+func TestGetStateInUseOtherUser(t *testing.T) {
+	var mask ipn.NotifyWatchOpt
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/localapi/v0/status", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, inUseMessage, http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/localapi/v0/watch-ipn-bus", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.ParseUint(r.URL.Query().Get("mask"), 10, 64)
+		mask = ipn.NotifyWatchOpt(n)
+
+		message := inUseMessage
+		json.NewEncoder(w).Encode(&ipn.Notify{State: ptr.To(ipn.InUseOtherUser), ErrMessage: &message})
+	})
+	fakeLocalAPI(t, mux)
+
+	state, err := GetState(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.BackendState != ipn.InUseOtherUser || state.InUseMessage != inUseMessage || state.InUseBy != `DESKTOP\bob` {
+		t.Errorf("got %v %q by %q", state.BackendState, state.InUseMessage, state.InUseBy)
+	}
+	if want := ipn.NotifyInitialState | ipn.NotifyNoPrivateKeys; mask != want {
+		t.Errorf("watched the IPN bus with mask %v, want %v", mask, want)
+	}
+}
+
+func TestGetStateOtherError(t *testing.T) {
+	watched := false
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/localapi/v0/status", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "something broke", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/localapi/v0/watch-ipn-bus", func(w http.ResponseWriter, r *http.Request) {
+		watched = true
+	})
+	fakeLocalAPI(t, mux)
+
+	if _, err := GetState(context.Background()); err == nil {
+		t.Error("got no error")
+	}
+	// Only the in-use error is worth checking the IPN bus for.
+	if watched {
+		t.Error("watched the IPN bus for an unrelated error")
+	}
+}
@@ -0,0 +1,152 @@
+package libts
+
+import (
+	"time"
+
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+)
+
+// Minimum time between two snapshots for their rates to be computed. State updates can happen
+// in quick succession (e.g. after changing a setting), and rates over tiny intervals are noise.
+const minTrafficInterval = 1 * time.Second
+
+// Number of total rate samples to keep in the history. With the default polling interval of a
+// few seconds, this covers the last few minutes.
+const trafficHistoryLen = 100
+
+// Traffic statistics of a single peer.
+type PeerTraffic struct {
+	Peer *ipnstate.PeerStatus
+
+	// Receive rate in bytes per second.
+	RxRate float64
+	// Send rate in bytes per second.
+	TxRate float64
+
+	// Bytes received from this peer since the tracker started.
+	SessionRxBytes int64
+	// Bytes sent to this peer since the tracker started.
+	SessionTxBytes int64
+}
+
+// Tracks per-peer traffic across successive State snapshots to compute throughput rates
+// and session totals.
+type TrafficTracker struct {
+	// Per-peer traffic sorted by PeerName.
+	Peers []*PeerTraffic
+
+	// Total receive rate in bytes per second.
+	RxRate float64
+	// Total send rate in bytes per second.
+	TxRate float64
+
+	// Total bytes received since the tracker started.
+	SessionRxBytes int64
+	// Total bytes sent since the tracker started.
+	SessionTxBytes int64
+
+	// Recent total receive rates, oldest first.
+	RxHistory []float64
+	// Recent total send rates, oldest first.
+	TxHistory []float64
+
+	// Time of the last snapshot used to compute rates.
+	lastTime time.Time
+	// Per-peer stats from the previous snapshot.
+	byID map[tailcfg.StableNodeID]*PeerTraffic
+	// Raw byte counters from the previous snapshot.
+	lastRx map[tailcfg.StableNodeID]int64
+	lastTx map[tailcfg.StableNodeID]int64
+}
+
+// Create a new, empty TrafficTracker.
+func NewTrafficTracker() *TrafficTracker {
+	return &TrafficTracker{
+		byID:   make(map[tailcfg.StableNodeID]*PeerTraffic),
+		lastRx: make(map[tailcfg.StableNodeID]int64),
+		lastTx: make(map[tailcfg.StableNodeID]int64),
+	}
+}
+
+// Record a new state snapshot taken at the given time, updating rates and totals.
+// Returns false if the snapshot was too close to the previous one and was ignored.
+func (t *TrafficTracker) Update(state State, now time.Time) bool {
+	elapsed := now.Sub(t.lastTime)
+	if !t.lastTime.IsZero() && elapsed < minTrafficInterval {
+		return false
+	}
+	isFirst := t.lastTime.IsZero()
+	t.lastTime = now
+
+	peers := make([]*PeerTraffic, 0, len(state.Peers))
+	byID := make(map[tailcfg.StableNodeID]*PeerTraffic, len(state.Peers))
+	// Only keep counters of peers in this snapshot, so a peer that leaves and comes back
+	// establishes a new baseline instead of being compared to a stale one.
+	lastRx := make(map[tailcfg.StableNodeID]int64, len(state.Peers))
+	lastTx := make(map[tailcfg.StableNodeID]int64, len(state.Peers))
+	t.RxRate = 0
+	t.TxRate = 0
+
+	for _, peer := range state.Peers {
+		traffic := &PeerTraffic{Peer: peer}
+		if prev, ok := t.byID[peer.ID]; ok {
+			traffic.SessionRxBytes = prev.SessionRxBytes
+			traffic.SessionTxBytes = prev.SessionTxBytes
+		}
+
+		// Peers we haven't seen before only establish a baseline.
+		prevRx, seen := t.lastRx[peer.ID]
+		if seen && !isFirst {
+			rxDelta := counterDelta(prevRx, peer.RxBytes)
+			txDelta := counterDelta(t.lastTx[peer.ID], peer.TxBytes)
+
+			traffic.SessionRxBytes += rxDelta
+			traffic.SessionTxBytes += txDelta
+			traffic.RxRate = float64(rxDelta) / elapsed.Seconds()
+			traffic.TxRate = float64(txDelta) / elapsed.Seconds()
+
+			t.SessionRxBytes += rxDelta
+			t.SessionTxBytes += txDelta
+			t.RxRate += traffic.RxRate
+			t.TxRate += traffic.TxRate
+		}
+
+		lastRx[peer.ID] = peer.RxBytes
+		lastTx[peer.ID] = peer.TxBytes
+
+		peers = append(peers, traffic)
+		byID[peer.ID] = traffic
+	}
+
+	t.Peers = peers
+	t.byID = byID
+	t.lastRx = lastRx
+	t.lastTx = lastTx
+
+	if !isFirst {
+		t.RxHistory = appendHistory(t.RxHistory, t.RxRate)
+		t.TxHistory = appendHistory(t.TxHistory, t.TxRate)
+	}
+
+	return true
+}
+
+// Get the number of bytes transferred between two readings of a byte counter.
+// Tailscale resets its counters when the connection to a peer is re-established,
+// in which case everything in the new counter is new.
+func counterDelta(prev int64, cur int64) int64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// Append a sample to a rate history, dropping the oldest samples past trafficHistoryLen.
+func appendHistory(history []float64, sample float64) []float64 {
+	history = append(history, sample)
+	if len(history) > trafficHistoryLen {
+		history = history[len(history)-trafficHistoryLen:]
+	}
+	return history
+}
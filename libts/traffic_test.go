@@ -0,0 +1,100 @@
+package libts
+
+import (
+	"testing"
+	"time"
+
+	"tailscale.com/ipn/ipnstate"
+)
+
+func TestTrafficTracker(t *testing.T) {
+	tracker := NewTrafficTracker()
+	start := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	// The first snapshot only sets the baseline.
+	if !tracker.Update(State{Peers: []*ipnstate.PeerStatus{
+		{ID: "a", RxBytes: 1000, TxBytes: 500},
+	}}, start) {
+		t.Fatal("first snapshot was ignored")
+	}
+	if tracker.RxRate != 0 || tracker.TxRate != 0 || tracker.SessionRxBytes != 0 {
+		t.Errorf("first snapshot has rates %v/%v and session total %d, want zero",
+			tracker.RxRate, tracker.TxRate, tracker.SessionRxBytes)
+	}
+
+	// Snapshots too close together are noise.
+	if tracker.Update(State{Peers: []*ipnstate.PeerStatus{
+		{ID: "a", RxBytes: 9000, TxBytes: 9000},
+	}}, start.Add(500*time.Millisecond)) {
+		t.Error("snapshot half a second later wasn't ignored")
+	}
+
+	// Peer b is new, so it only sets its baseline.
+	tracker.Update(State{Peers: []*ipnstate.PeerStatus{
+		{ID: "a", RxBytes: 5000, TxBytes: 1500},
+		{ID: "b", RxBytes: 7000, TxBytes: 7000},
+	}}, start.Add(2*time.Second))
+
+	a, b := tracker.Peers[0], tracker.Peers[1]
+	if a.RxRate != 2000 || a.TxRate != 500 {
+		t.Errorf("got peer a rates %v/%v, want 2000/500", a.RxRate, a.TxRate)
+	}
+	if b.RxRate != 0 || b.TxRate != 0 {
+		t.Errorf("got new peer b rates %v/%v, want 0/0", b.RxRate, b.TxRate)
+	}
+	if tracker.RxRate != 2000 || tracker.TxRate != 500 {
+		t.Errorf("got total rates %v/%v, want 2000/500", tracker.RxRate, tracker.TxRate)
+	}
+
+	// Peer a's counters reset after reconnecting, so everything in them is new.
+	tracker.Update(State{Peers: []*ipnstate.PeerStatus{
+		{ID: "a", RxBytes: 400, TxBytes: 1500},
+		{ID: "b", RxBytes: 8000, TxBytes: 7100},
+	}}, start.Add(6*time.Second))
+
+	a, b = tracker.Peers[0], tracker.Peers[1]
+	if a.RxRate != 100 || a.TxRate != 0 {
+		t.Errorf("got peer a rates %v/%v after a counter reset, want 100/0", a.RxRate, a.TxRate)
+	}
+	if b.RxRate != 250 || b.TxRate != 25 {
+		t.Errorf("got peer b rates %v/%v, want 250/25", b.RxRate, b.TxRate)
+	}
+	if a.SessionRxBytes != 4400 || a.SessionTxBytes != 1000 {
+		t.Errorf("got peer a session totals %d/%d, want 4400/1000", a.SessionRxBytes, a.SessionTxBytes)
+	}
+	if tracker.SessionRxBytes != 5400 || tracker.SessionTxBytes != 1100 {
+		t.Errorf("got session totals %d/%d, want 5400/1100", tracker.SessionRxBytes, tracker.SessionTxBytes)
+	}
+}
+
+func TestCounterDelta(t *testing.T) {
+	if got := counterDelta(100, 250); got != 150 {
+		t.Errorf("counterDelta(100, 250) = %d, want 150", got)
+	}
+	if got := counterDelta(100, 100); got != 0 {
+		t.Errorf("counterDelta(100, 100) = %d, want 0", got)
+	}
+	if got := counterDelta(100, 40); got != 40 {
+		t.Errorf("counterDelta(100, 40) = %d after a reset, want 40", got)
+	}
+}
+
+func TestTrafficTrackerReturningPeer(t *testing.T) {
+	tracker := NewTrafficTracker()
+	start := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tracker.Update(State{Peers: []*ipnstate.PeerStatus{{ID: "a", RxBytes: 1000}}}, start)
+	tracker.Update(State{Peers: []*ipnstate.PeerStatus{}}, start.Add(2*time.Second))
+
+	// Peer a comes back with a counter that kept going while it was gone. That traffic
+	// happened over an unknown period, so it only sets a new baseline.
+	tracker.Update(State{Peers: []*ipnstate.PeerStatus{{ID: "a", RxBytes: 50_000}}}, start.Add(4*time.Second))
+	if rate := tracker.Peers[0].RxRate; rate != 0 {
+		t.Errorf("got rate %v for a returning peer, want 0", rate)
+	}
+
+	tracker.Update(State{Peers: []*ipnstate.PeerStatus{{ID: "a", RxBytes: 52_000}}}, start.Add(6*time.Second))
+	if rate := tracker.Peers[0].RxRate; rate != 1000 {
+		t.Errorf("got rate %v after the new baseline, want 1000", rate)
+	}
+}
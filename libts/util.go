@@ -2,6 +2,7 @@ package libts
 
 import (
 	"strings"
+	"time"
 
 	"tailscale.com/ipn/ipnstate"
 )
@@ -18,3 +19,28 @@ func PeerName(peer *ipnstate.PeerStatus) string {
 
 	return peer.DNSName[:dotIndex]
 }
+
+// Get the address to connect to a peer at: its MagicDNS name, or its first IP if it doesn't
+// have one.
+func PeerHost(peer *ipnstate.PeerStatus) string {
+	host := strings.TrimSuffix(peer.DNSName, ".")
+	if host == "" && len(peer.TailscaleIPs) > 0 {
+		host = peer.TailscaleIPs[0].String()
+	}
+	return host
+}
+
+// Get how long until a peer's node key expires, which is negative if it already has.
+// Returns false if the key never expires, such as for tagged devices.
+func KeyExpiresIn(peer *ipnstate.PeerStatus, now time.Time) (time.Duration, bool) {
+	if peer == nil || peer.KeyExpiry == nil {
+		return 0, false
+	}
+	return peer.KeyExpiry.Sub(now), true
+}
+
+// Returns true if a peer is running the Tailscale SSH server, which we can tell because
+// it advertises SSH host keys.
+func PeerHasSSH(peer *ipnstate.PeerStatus) bool {
+	return len(peer.SSH_HostKeys) > 0
+}
@@ -0,0 +1,14 @@
+package libts
+
+import (
+	"tailscale.com/util/cmpver"
+)
+
+// Returns true if a Tailscale version like "1.70.0" is at least minVersion.
+// An empty version is unknown, so it's assumed to be new enough.
+func VersionAtLeast(version string, minVersion string) bool {
+	if version == "" {
+		return true
+	}
+	return cmpver.Compare(version, minVersion) >= 0
+}
@@ -0,0 +1,12 @@
+package libts
+
+import (
+	"context"
+
+	"tailscale.com/client/tailscale/apitype"
+)
+
+// Look up the node and user that own a Tailscale IP address, given as "ip" or "ip:port".
+func WhoIs(ctx context.Context, addr string) (*apitype.WhoIsResponse, error) {
+	return ts.WhoIs(ctx, addr)
+}
@@ -1,22 +1,338 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
 	"math"
 	"runtime"
+	"slices"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/neuralinkcorp/tsui/actions"
 	"github.com/neuralinkcorp/tsui/clipboard"
+	"github.com/neuralinkcorp/tsui/config"
+	"github.com/neuralinkcorp/tsui/eventlog"
+	"github.com/neuralinkcorp/tsui/export"
 	"github.com/neuralinkcorp/tsui/libts"
 	"github.com/neuralinkcorp/tsui/ui"
 	"tailscale.com/ipn"
 	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
 	"tailscale.com/types/opt"
 	"tailscale.com/types/preftype"
 )
 
-func buildNetworkDevicesSubmenuSection(title string, peers []*ipnstate.PeerStatus) []ui.SubmenuItem {
+// Join non-empty label parts with a separator.
+func joinLabels(parts ...string) string {
+	parts = slices.DeleteFunc(parts, func(part string) bool {
+		return part == ""
+	})
+	return strings.Join(parts, " · ")
+}
+
+// Pick the additional label color for a peer's connection path. Relayed peers are highlighted,
+// since traffic over DERP is slower and usually means NAT traversal failed.
+func pathColor(path libts.PeerPath) lipgloss.Color {
+	if path.IsRelayed {
+		return ui.Yellow
+	}
+	return ""
+}
+
+// Wrap a setting that depends on an optional Tailscale feature. If the daemon doesn't support
+// the feature, the setting is replaced with a dimmed item explaining which version is needed.
+func requireFeature(caps libts.Capabilities, feature libts.Feature, item *ui.SettingSubmenuItem) ui.SubmenuItem {
+	if caps.Supports(feature) {
+		return item
+	}
+
+	return &ui.LabeledSubmenuItem{
+		Label:           item.Label,
+		AdditionalLabel: "needs " + feature.MinVersion() + "+",
+		IsDim:           true,
+		OnActivate: func() tea.Msg {
+			return errorMsg(caps.Require(feature))
+		},
+	}
+}
+
+// Normalize the capitalization of an OS name, because some are capitalized but some aren't.
+func formatOSName(osName string) string {
+	switch osName {
+	case "android":
+		return "Android"
+	case "windows":
+		return "Windows"
+	case "linux":
+		return "Linux"
+	}
+	return osName
+}
+
+// Get the label and clipboard message description of a copy format.
+func copyFormatLabels(format config.CopyFormat) (label string, description string) {
+	switch format {
+	case config.CopyIPv4:
+		return "IPv4 Address", "IPv4 address"
+	case config.CopyIPv6:
+		return "IPv6 Address", "IPv6 address"
+	case config.CopyName:
+		return "Name", "name"
+	case config.CopyFQDN:
+		return "Full Domain", "full domain"
+	case config.CopySSH:
+		return "SSH Command", "SSH command"
+	case config.CopyURL:
+		return "URL", "URL"
+	case config.CopyID:
+		return "Node ID", "Tailscale node ID"
+	}
+	return string(format), string(format)
+}
+
+// Format a device for copying. Returns false if the device doesn't have a value in that
+// format, like an IPv6 address when IPv6 is off.
+func (m *model) formatForCopy(peer *ipnstate.PeerStatus, format config.CopyFormat) (string, bool) {
+	var value string
+
+	switch format {
+	case config.CopyIPv4, config.CopyIPv6:
+		for _, addr := range peer.TailscaleIPs {
+			if addr.Is4() == (format == config.CopyIPv4) {
+				value = addr.String()
+				break
+			}
+		}
+	case config.CopyName:
+		value = libts.PeerName(peer)
+	case config.CopyFQDN:
+		value = strings.TrimSuffix(peer.DNSName, ".")
+	case config.CopySSH:
+		host := libts.PeerHost(peer)
+		if user := m.config.SSH.UserFor(libts.PeerName(peer), string(peer.ID)); user != "" {
+			host = user + "@" + host
+		}
+		value = "ssh " + host
+	case config.CopyURL:
+		value = "http://" + libts.PeerHost(peer)
+	case config.CopyID:
+		value = string(peer.ID)
+	}
+
+	return value, value != ""
+}
+
+// Build the quick copy item and "Copy As" submenu for a device. The quick copy item uses the
+// default format and is left out if the device doesn't have a value in that format.
+func (m *model) copySubmenuItems(peer *ipnstate.PeerStatus, defaultFormat config.CopyFormat) []ui.SubmenuItem {
+	submenu, ok := m.copyMenus[peer.ID]
+	if !ok {
+		submenu = &ui.Submenu{}
+		m.copyMenus[peer.ID] = submenu
+	}
+
+	peerName := libts.PeerName(peer)
+	items := []ui.SubmenuItem{}
+
+	if value, ok := m.formatForCopy(peer, defaultFormat); ok {
+		label, description := copyFormatLabels(defaultFormat)
+		items = append(items, &ui.LabeledSubmenuItem{
+			Label:      "[Copy " + label + "]",
+			OnActivate: makeCopyToClipboard(value, description+" of "+peerName),
+		})
+	}
+
+	formatItems := []ui.SubmenuItem{}
+	for _, format := range config.CopyFormats {
+		value, ok := m.formatForCopy(peer, format)
+		if !ok {
+			continue
+		}
+
+		label, description := copyFormatLabels(format)
+		formatItems = append(formatItems, copyableSubmenuItem(label, value, value, description+" of "+peerName))
+	}
+	submenu.SetItems(formatItems)
+
+	items = append(items, &ui.NestedSubmenuItem{
+		LabeledSubmenuItem: ui.LabeledSubmenuItem{Label: "Copy As"},
+		Submenu:            submenu,
+	})
+	return items
+}
+
+// Get the label of an export format, like "Ansible Inventory".
+func exportFormatLabel(format export.Format) string {
+	switch format {
+	case export.FormatHosts:
+		return "Hosts File"
+	case export.FormatSSHConfig:
+		return "SSH Config"
+	case export.FormatAnsible:
+		return "Ansible Inventory"
+	case export.FormatCSV:
+		return "CSV"
+	}
+	return string(format)
+}
+
+// Create a setting that picks one of values to filter exports by, or "All". Keeps the current
+// value as an option even if no peer has it anymore. onChange gets an empty string for "All".
+func exportFilterItem(label string, values []string, current string, onChange func(value string) tea.Msg) ui.SubmenuItem {
+	values = slices.Clone(values)
+	if current != "" {
+		values = append(values, current)
+	}
+	slices.Sort(values)
+	values = slices.Compact(values)
+
+	if current == "" {
+		current = "All"
+	}
+	return ui.NewSettingsSubmenuItem(label, append([]string{"All"}, values...), current,
+		func(newLabel string) tea.Msg {
+			if newLabel == "All" {
+				newLabel = ""
+			}
+			return onChange(newLabel)
+		},
+	)
+}
+
+// Create a submenu item that copies a value to the clipboard when activated.
+func copyableSubmenuItem(label string, additionalLabel string, value string, description string) *ui.LabeledSubmenuItem {
+	return &ui.LabeledSubmenuItem{
+		Label:           label,
+		AdditionalLabel: additionalLabel,
+		OnActivate:      makeCopyToClipboard(value, description),
+	}
+}
+
+// Get the details submenu for a peer, creating it if needed, and update its items.
+// Submenus are kept across updates so their cursor position survives.
+func (m *model) peerDetailsSubmenu(peer *ipnstate.PeerStatus) *ui.Submenu {
+	submenu, ok := m.peerDetails[peer.ID]
+	if !ok {
+		submenu = &ui.Submenu{}
+		m.peerDetails[peer.ID] = submenu
+	}
+
+	peerName := libts.PeerName(peer)
+	path := libts.GetPeerPath(peer, m.pings[peer.ID])
+
+	statusLabel := "Online"
+	if !peer.Online {
+		statusLabel = "Offline"
+		if !peer.LastSeen.IsZero() {
+			statusLabel += ", last seen " + ui.FormatDuration(time.Since(peer.LastSeen)) + " ago"
+		}
+	}
+
+	pathLabel := path.String()
+	if pathLabel == "" {
+		pathLabel = "Idle"
+	}
+
+	items := []ui.SubmenuItem{}
+
+	// Peers running Tailscale SSH advertise their host keys.
+	if libts.PeerHasSSH(peer) {
+		user := m.config.SSH.UserFor(peerName, string(peer.ID))
+
+		additionalLabel := ""
+		if user != "" {
+			additionalLabel = "as " + user
+		}
+
+		items = append(items, &ui.LabeledSubmenuItem{
+			Label:           "[SSH to This Device]",
+			AdditionalLabel: additionalLabel,
+			Variant:         ui.SubmenuItemVariantAccent,
+			OnActivate:      makeSSHToPeer(peer, user),
+		})
+	}
+
+	isFavorite := m.favorites.Has(peer.ID)
+	favoriteLabel := "[Add to Favorites]"
+	if isFavorite {
+		favoriteLabel = "[Remove from Favorites]"
+	}
+
+	items = append(items, m.copySubmenuItems(peer, m.config.Copy.Peers)...)
+	items = append(items,
+		&ui.LabeledSubmenuItem{
+			Label: favoriteLabel,
+			OnActivate: func() tea.Msg {
+				return setFavoriteMsg{peer: peer, isFavorite: !isFavorite}
+			},
+		},
+	)
+
+	// Custom actions from the config file.
+	if peerActions := actions.For(m.config.Actions, peer); len(peerActions) > 0 {
+		items = append(items,
+			&ui.SpacerSubmenuItem{},
+			&ui.TitleSubmenuItem{Label: "Actions"},
+		)
+		for _, action := range peerActions {
+			items = append(items, &ui.LabeledSubmenuItem{
+				Label:      "[" + action.Label + "]",
+				OnActivate: makeRunAction(action, peer),
+			})
+		}
+	}
+
+	items = append(items,
+		&ui.SpacerSubmenuItem{},
+		&ui.TitleSubmenuItem{Label: "Name"},
+		copyableSubmenuItem(strings.TrimSuffix(peer.DNSName, "."), "", strings.TrimSuffix(peer.DNSName, "."), "full domain"),
+
+		&ui.SpacerSubmenuItem{},
+		&ui.TitleSubmenuItem{Label: "IPs"},
+	)
+
+	for _, addr := range peer.TailscaleIPs {
+		items = append(items, copyableSubmenuItem(addr.String(), "", addr.String(), "IP address"))
+	}
+
+	items = append(items,
+		&ui.SpacerSubmenuItem{},
+		&ui.TitleSubmenuItem{Label: "Info"},
+		&ui.LabeledSubmenuItem{Label: "Status", AdditionalLabel: statusLabel, IsDim: !peer.Online},
+		&ui.LabeledSubmenuItem{Label: "Connection", AdditionalLabel: pathLabel, AdditionalLabelColor: pathColor(path)},
+		&ui.LabeledSubmenuItem{Label: "OS", AdditionalLabel: formatOSName(peer.OS)},
+	)
+
+	if peer.IsTagged() {
+		items = append(items, &ui.LabeledSubmenuItem{
+			Label:           "Tags",
+			AdditionalLabel: strings.Join(peer.Tags.AsSlice(), ", "),
+		})
+	} else if owner := m.state.PeerOwner(peer); owner != "" {
+		items = append(items, copyableSubmenuItem("Owner", owner, owner, "owner"))
+	}
+
+	if remaining, ok := libts.KeyExpiresIn(peer, time.Now()); ok {
+		item := &ui.LabeledSubmenuItem{Label: "Key", AdditionalLabel: formatKeyExpiry(remaining)}
+		// Only draw attention to keys that would show up under Expiring Keys.
+		if remaining < time.Duration(m.config.KeyExpiryWarning) {
+			item.AdditionalLabelColor = keyExpiryColor(remaining)
+		}
+		items = append(items, item)
+	}
+
+	items = append(items,
+		copyableSubmenuItem("ID", string(peer.ID), string(peer.ID), "Tailscale node ID"),
+	)
+
+	submenu.SetItems(items)
+	return submenu
+}
+
+func (m *model) buildNetworkDevicesSubmenuSection(title string, peers []*ipnstate.PeerStatus) []ui.SubmenuItem {
 	items := []ui.SubmenuItem{
 		&ui.TitleSubmenuItem{Label: title},
 	}
@@ -25,30 +341,20 @@ func buildNetworkDevicesSubmenuSection(title string, peers []*ipnstate.PeerStatu
 		items = append(items, &ui.DividerSubmenuItem{})
 	} else {
 		for _, peer := range peers {
-			peerName := libts.PeerName(peer)
+			path := libts.GetPeerPath(peer, m.pings[peer.ID])
 
-			// Normalize the capitalization of the OS, because some are capitalized but some aren't.
-			osName := peer.OS
-			switch osName {
-			case "android":
-				osName = "Android"
-			case "windows":
-				osName = "Windows"
-			case "linux":
-				osName = "Linux"
+			sshLabel := ""
+			if libts.PeerHasSSH(peer) {
+				sshLabel = "SSH"
 			}
 
-			items = append(items, &ui.LabeledSubmenuItem{
-				Label:           peerName,
-				AdditionalLabel: osName,
-				OnActivate: func() tea.Msg {
-					err := clipboard.WriteString(peer.TailscaleIPs[0].String())
-					if err != nil {
-						return errorMsg(err)
-					}
-					return successMsg(fmt.Sprintf("Copied IP address of %s.", peerName))
+			items = append(items, &ui.NestedSubmenuItem{
+				LabeledSubmenuItem: ui.LabeledSubmenuItem{
+					Label:                libts.PeerName(peer),
+					AdditionalLabel:      joinLabels(formatOSName(peer.OS), sshLabel, path.String()),
+					AdditionalLabelColor: pathColor(path),
 				},
-				IsDim: false,
+				Submenu: m.peerDetailsSubmenu(peer),
 			})
 		}
 	}
@@ -56,12 +362,244 @@ func buildNetworkDevicesSubmenuSection(title string, peers []*ipnstate.PeerStatu
 	return items
 }
 
+// Get the favorite peers from a list, keeping their order.
+func (m *model) favoritePeers(peers []*ipnstate.PeerStatus) []*ipnstate.PeerStatus {
+	return slices.DeleteFunc(slices.Clone(peers), func(peer *ipnstate.PeerStatus) bool {
+		return !m.favorites.Has(peer.ID)
+	})
+}
+
+// Returns the danger variant if the condition is true, for highlighting problems.
+func dangerIf(condition bool) ui.SubmenuItemVariant {
+	if condition {
+		return ui.SubmenuItemVariantDanger
+	}
+	return ui.SubmenuItemVariantDefault
+}
+
+// Format a pair of receive and send values like the bottom bar does.
+func formatRxTx(rx string, tx string) string {
+	return fmt.Sprintf("▼ %s | %s ▲", rx, tx)
+}
+
+// Get the highest rate in a rate history, or 0 if it's empty.
+func maxRate(history []float64) float64 {
+	if len(history) == 0 {
+		return 0
+	}
+	return slices.Max(history)
+}
+
+// Sort per-peer traffic for display. sortBy is "Rate", "Total", or "Name".
+func sortPeerTraffic(peers []*libts.PeerTraffic, sortBy string) []*libts.PeerTraffic {
+	// Peers are already sorted by name, so a stable sort keeps ties in alphabetical order.
+	sorted := slices.Clone(peers)
+
+	switch sortBy {
+	case "Rate":
+		slices.SortStableFunc(sorted, func(a, b *libts.PeerTraffic) int {
+			return cmp.Compare(b.RxRate+b.TxRate, a.RxRate+a.TxRate)
+		})
+	case "Total":
+		slices.SortStableFunc(sorted, func(a, b *libts.PeerTraffic) int {
+			return cmp.Compare(b.Peer.RxBytes+b.Peer.TxBytes, a.Peer.RxBytes+a.Peer.TxBytes)
+		})
+	}
+
+	return sorted
+}
+
+// A titled section of peers in the network devices submenu.
+type peerGroup struct {
+	title string
+	peers []*ipnstate.PeerStatus
+	// Whether to show the section with a divider when it has no peers, rather than hiding it.
+	showEmpty bool
+}
+
+// Filter and sort peers for the network devices submenu according to the user's options.
+func (m *model) arrangePeers(peers []*ipnstate.PeerStatus) []*ipnstate.PeerStatus {
+	// Peers are already sorted by name, so a stable sort keeps ties in alphabetical order.
+	sorted := slices.Clone(peers)
+	if m.hideOffline {
+		sorted = slices.DeleteFunc(sorted, func(peer *ipnstate.PeerStatus) bool {
+			return !peer.Online
+		})
+	}
+
+	switch m.devicesSort {
+	case "Online":
+		slices.SortStableFunc(sorted, func(a, b *ipnstate.PeerStatus) int {
+			return compareOnline(a, b)
+		})
+	case "Last Seen":
+		// Online peers are being seen right now, so they go first.
+		slices.SortStableFunc(sorted, func(a, b *ipnstate.PeerStatus) int {
+			if c := compareOnline(a, b); c != 0 {
+				return c
+			}
+			return b.LastSeen.Compare(a.LastSeen)
+		})
+	case "Latency":
+		// Peers we haven't pinged go last.
+		slices.SortStableFunc(sorted, func(a, b *ipnstate.PeerStatus) int {
+			pingA, pingB := m.pings[a.ID], m.pings[b.ID]
+			switch {
+			case pingA == nil && pingB == nil:
+				return 0
+			case pingA == nil:
+				return 1
+			case pingB == nil:
+				return -1
+			}
+			return cmp.Compare(pingA.LatencySeconds, pingB.LatencySeconds)
+		})
+	case "Traffic":
+		slices.SortStableFunc(sorted, func(a, b *ipnstate.PeerStatus) int {
+			return cmp.Compare(b.RxBytes+b.TxBytes, a.RxBytes+a.TxBytes)
+		})
+	}
+
+	return sorted
+}
+
+// Compare two peers so online ones sort first.
+func compareOnline(a, b *ipnstate.PeerStatus) int {
+	switch {
+	case a.Online == b.Online:
+		return 0
+	case a.Online:
+		return -1
+	}
+	return 1
+}
+
+// Split peers into sections for the network devices submenu, keeping their order. groupBy is
+// "Owner", "OS", "Tag", or "Status".
+func (m *model) groupPeers(peers []*ipnstate.PeerStatus, groupBy string) []peerGroup {
+	switch groupBy {
+	case "OS":
+		byOS := make(map[string][]*ipnstate.PeerStatus)
+		osNames := []string{}
+		for _, peer := range peers {
+			osName := formatOSName(peer.OS)
+			if osName == "" {
+				osName = "Unknown"
+			}
+			if _, ok := byOS[osName]; !ok {
+				osNames = append(osNames, osName)
+			}
+			byOS[osName] = append(byOS[osName], peer)
+		}
+		slices.Sort(osNames)
+
+		groups := []peerGroup{}
+		for _, osName := range osNames {
+			groups = append(groups, peerGroup{title: osName, peers: byOS[osName]})
+		}
+		return groups
+
+	case "Tag":
+		// Peers with several tags show up in each of their sections.
+		byTag := make(map[string][]*ipnstate.PeerStatus)
+		tags := []string{}
+		untagged := []*ipnstate.PeerStatus{}
+		for _, peer := range peers {
+			if !peer.IsTagged() {
+				untagged = append(untagged, peer)
+				continue
+			}
+			for _, tag := range peer.Tags.AsSlice() {
+				if _, ok := byTag[tag]; !ok {
+					tags = append(tags, tag)
+				}
+				byTag[tag] = append(byTag[tag], peer)
+			}
+		}
+		slices.Sort(tags)
+
+		groups := []peerGroup{}
+		for _, tag := range tags {
+			groups = append(groups, peerGroup{title: tag, peers: byTag[tag]})
+		}
+		return append(groups, peerGroup{title: "Untagged", peers: untagged})
+
+	case "Status":
+		online := []*ipnstate.PeerStatus{}
+		offline := []*ipnstate.PeerStatus{}
+		for _, peer := range peers {
+			if peer.Online {
+				online = append(online, peer)
+			} else {
+				offline = append(offline, peer)
+			}
+		}
+		return []peerGroup{
+			{title: "Online", peers: online},
+			{title: "Offline", peers: offline},
+		}
+	}
+
+	// Owner sections come from the state's lists, which are already split by owner.
+	groups := []peerGroup{
+		{title: "My Devices", showEmpty: true},
+		{title: "Tagged Devices", showEmpty: true},
+	}
+	groupIndexes := make(map[tailcfg.StableNodeID]int)
+	for _, peer := range m.state.MyNodes {
+		groupIndexes[peer.ID] = 0
+	}
+	for _, peer := range m.state.TaggedNodes {
+		groupIndexes[peer.ID] = 1
+	}
+	for _, key := range m.state.OwnedNodeKeys {
+		title := key
+		if title == "" {
+			title = "<none>"
+		}
+		groups = append(groups, peerGroup{title: title, showEmpty: true})
+		for _, peer := range m.state.OwnedNodes[key] {
+			groupIndexes[peer.ID] = len(groups) - 1
+		}
+	}
+
+	for _, peer := range peers {
+		if index, ok := groupIndexes[peer.ID]; ok {
+			groups[index].peers = append(groups[index].peers, peer)
+		}
+	}
+	return groups
+}
+
+// Delete persistent submenus of devices that have left the network, so they don't pile up over
+// a long session.
+func (m *model) pruneSubmenus(submenus map[tailcfg.StableNodeID]*ui.Submenu) {
+	ids := make(map[tailcfg.StableNodeID]bool, len(m.state.Peers)+1)
+	for _, peer := range m.state.Peers {
+		ids[peer.ID] = true
+	}
+	if m.state.Self != nil {
+		ids[m.state.Self.ID] = true
+	}
+
+	for id := range submenus {
+		if !ids[id] {
+			delete(submenus, id)
+		}
+	}
+}
+
 // Update all of the menu UIs from the current state.
 func (m *model) updateMenus() {
 	if m.state.BackendState == ipn.Running {
+		m.pruneSubmenus(m.peerDetails)
+		m.pruneSubmenus(m.copyMenus)
+
 		// Update the device info submenu.
 		{
-			submenuItems := []ui.SubmenuItem{
+			submenuItems := m.copySubmenuItems(m.state.Self, m.config.Copy.ThisDevice)
+			submenuItems = append(submenuItems,
+				&ui.SpacerSubmenuItem{},
 				&ui.TitleSubmenuItem{Label: "Name"},
 				&ui.LabeledSubmenuItem{
 					Label: m.state.Self.DNSName[:len(m.state.Self.DNSName)-1], // Remove the trailing dot.
@@ -75,7 +613,7 @@ func (m *model) updateMenus() {
 				},
 				&ui.SpacerSubmenuItem{},
 				&ui.TitleSubmenuItem{Label: "IPs"},
-			}
+			)
 
 			for _, addr := range m.state.Self.TailscaleIPs {
 				submenuItems = append(submenuItems, &ui.LabeledSubmenuItem{
@@ -121,126 +659,706 @@ func (m *model) updateMenus() {
 						return successMsg("Copied node key to clipboard.")
 					},
 				},
-			)
+			)
+
+			if m.state.LockKey != nil {
+				statusText := "Online"
+				if m.state.IsLockedOut {
+					statusText = "Locked Out"
+				}
+
+				submenuItems = append(submenuItems,
+					&ui.SpacerSubmenuItem{},
+					&ui.TitleSubmenuItem{Label: "Tailnet Lock: " + statusText},
+					&ui.LabeledSubmenuItem{
+						Label: m.state.LockKey.CLIString(),
+						OnActivate: func() tea.Msg {
+							err := clipboard.WriteString(m.state.LockKey.CLIString())
+							if err != nil {
+								return errorMsg(err)
+							}
+							return successMsg("Copied tailnet lock key to clipboard.")
+						},
+					},
+				)
+			}
+
+			submenuItems = append(submenuItems,
+				&ui.SpacerSubmenuItem{},
+				&ui.LabeledSubmenuItem{
+					Label:   "[Disconnect from Tailscale]",
+					Variant: ui.SubmenuItemVariantAccent,
+					OnActivate: func() tea.Msg {
+						err := libts.Down(ctx)
+						if err != nil {
+							return errorMsg(err)
+						}
+						return tipMsg("You can also simply press . to disconnect.")
+					},
+				},
+			)
+
+			m.deviceInfo.Submenu.SetItems(submenuItems)
+		}
+
+		// Update the exit node submenu.
+		{
+			// Each exit node gets an item to use it, followed by one to star or unstar it.
+			exitNodeItems := func(exitNode *ipnstate.PeerStatus) []ui.SubmenuItem {
+				pingLabel := "???"
+				if !exitNode.Online {
+					pingLabel = "Offline"
+				} else if m.pings[exitNode.ID] != nil {
+					pingLabel = fmt.Sprintf("%dms", int(math.Round(m.pings[exitNode.ID].LatencySeconds*1000)))
+				}
+
+				var path libts.PeerPath
+				if exitNode.Online {
+					path = libts.GetPeerPath(exitNode, m.pings[exitNode.ID])
+				}
+
+				isFavorite := m.favorites.Has(exitNode.ID)
+				favoriteLabel := "  [Star]"
+				if isFavorite {
+					favoriteLabel = "  [Unstar]"
+				}
+
+				return []ui.SubmenuItem{
+					&ui.ToggleableSubmenuItem{
+						LabeledSubmenuItem: ui.LabeledSubmenuItem{
+							Label:                libts.PeerName(exitNode),
+							AdditionalLabel:      joinLabels(pingLabel, path.String()),
+							AdditionalLabelColor: pathColor(path),
+							OnActivate: func() tea.Msg {
+								err := libts.SetExitNode(ctx, exitNode)
+								if err != nil {
+									return errorMsg(err)
+								}
+								return updateState()
+							},
+							IsDim: !exitNode.Online,
+						},
+						IsActive: m.state.CurrentExitNode != nil && exitNode.ID == *m.state.CurrentExitNode,
+					},
+					&ui.LabeledSubmenuItem{
+						Label: favoriteLabel,
+						OnActivate: func() tea.Msg {
+							return setFavoriteMsg{peer: exitNode, isFavorite: !isFavorite}
+						},
+						IsDim: true,
+					},
+				}
+			}
+
+			submenuItems := []ui.SubmenuItem{}
+			submenuItems = append(submenuItems, &ui.ToggleableSubmenuItem{
+				LabeledSubmenuItem: ui.LabeledSubmenuItem{
+					Label: "None",
+					OnActivate: func() tea.Msg {
+						err := libts.SetExitNode(ctx, nil)
+						if err != nil {
+							return errorMsg(err)
+						}
+						return updateState()
+					},
+				},
+				IsActive: m.state.CurrentExitNode == nil,
+			})
+			submenuItems = append(submenuItems, &ui.DividerSubmenuItem{})
+
+			// Favorites are repeated at the top so they're quick to get to.
+			favoriteExitNodes := m.favoritePeers(m.state.ExitNodes)
+			if len(favoriteExitNodes) > 0 {
+				submenuItems = append(submenuItems, &ui.TitleSubmenuItem{Label: "Favorites"})
+				for _, exitNode := range favoriteExitNodes {
+					submenuItems = append(submenuItems, exitNodeItems(exitNode)...)
+				}
+				submenuItems = append(submenuItems,
+					&ui.SpacerSubmenuItem{},
+					&ui.TitleSubmenuItem{Label: "All Exit Nodes"},
+				)
+			}
+
+			for _, exitNode := range m.state.ExitNodes {
+				submenuItems = append(submenuItems, exitNodeItems(exitNode)...)
+			}
+
+			m.exitNodes.AdditionalLabel = m.state.CurrentExitNodeName
+			m.exitNodes.Submenu.SetItems(submenuItems)
+		}
+
+		// Update the network devices submenu.
+		{
+			// The export submenu has its own filters, like the command line.
+			owners := []string{}
+			tags := []string{}
+			osNames := []string{}
+			for _, peer := range m.state.Peers {
+				if owner := m.state.PeerOwner(peer); owner != "" {
+					owners = append(owners, owner)
+				}
+				if peer.IsTagged() {
+					tags = append(tags, peer.Tags.AsSlice()...)
+				}
+				if peer.OS != "" {
+					osNames = append(osNames, formatOSName(peer.OS))
+				}
+			}
+
+			filter := m.exportFilter
+			exportItems := []ui.SubmenuItem{
+				&ui.TitleSubmenuItem{Label: "Filters"},
+				exportFilterItem("Owner", owners, filter.Owner, func(value string) tea.Msg {
+					newFilter := filter
+					newFilter.Owner = value
+					return exportFilterMsg(newFilter)
+				}),
+				exportFilterItem("Tag", tags, filter.Tag, func(value string) tea.Msg {
+					newFilter := filter
+					newFilter.Tag = value
+					return exportFilterMsg(newFilter)
+				}),
+				exportFilterItem("OS", osNames, filter.OS, func(value string) tea.Msg {
+					newFilter := filter
+					newFilter.OS = value
+					return exportFilterMsg(newFilter)
+				}),
+				ui.NewYesNoSettingsSubmenuItem("Online Only", filter.OnlineOnly, func(yes bool) tea.Msg {
+					newFilter := filter
+					newFilter.OnlineOnly = yes
+					return exportFilterMsg(newFilter)
+				}),
+				&ui.SpacerSubmenuItem{},
+				&ui.TitleSubmenuItem{Label: "Save to Current Directory"},
+			}
+			for _, format := range export.Formats {
+				exportItems = append(exportItems, &ui.LabeledSubmenuItem{
+					Label:           "[" + exportFormatLabel(format) + "]",
+					AdditionalLabel: format.FileName(),
+					OnActivate:      makeExportPeers(format, m.state, filter, m.config.SSH),
+				})
+			}
+			m.exportMenu.SetItems(exportItems)
+
+			networkNodes := []ui.SubmenuItem{
+				ui.NewSettingsSubmenuItem("Sort By",
+					[]string{"Name", "Online", "Last Seen", "Latency", "Traffic"},
+					m.devicesSort,
+					func(newLabel string) tea.Msg {
+						return devicesSortMsg(newLabel)
+					},
+				),
+				ui.NewSettingsSubmenuItem("Group By",
+					[]string{"Owner", "OS", "Tag", "Status"},
+					m.devicesGroup,
+					func(newLabel string) tea.Msg {
+						return devicesGroupMsg(newLabel)
+					},
+				),
+				ui.NewYesNoSettingsSubmenuItem("Hide Offline", m.hideOffline, func(yes bool) tea.Msg {
+					return hideOfflineMsg(yes)
+				}),
+				&ui.NestedSubmenuItem{
+					LabeledSubmenuItem: ui.LabeledSubmenuItem{
+						Label:           "Export",
+						AdditionalLabel: fmt.Sprintf("%d devices", len(export.Peers(m.state, filter))),
+					},
+					Submenu: m.exportMenu,
+				},
+				&ui.SpacerSubmenuItem{},
+			}
+
+			// Every section shows peers in the same filtered and sorted order.
+			arrangedPeers := m.arrangePeers(m.state.Peers)
+
+			// Favorites go first, since they're the ones used most.
+			if favoritePeers := m.favoritePeers(arrangedPeers); len(favoritePeers) > 0 {
+				networkNodes = append(networkNodes,
+					m.buildNetworkDevicesSubmenuSection("Favorites", favoritePeers)...)
+				networkNodes = append(networkNodes,
+					&ui.SpacerSubmenuItem{})
+			}
+
+			// Surface peers that are about to drop off the network next.
+			now := time.Now()
+			keyExpiryWarning := time.Duration(m.config.KeyExpiryWarning)
+			expiringItems := []ui.SubmenuItem{}
+			for _, peer := range arrangedPeers {
+				remaining, ok := libts.KeyExpiresIn(peer, now)
+				if !ok || remaining >= keyExpiryWarning {
+					continue
+				}
+
+				expiringItems = append(expiringItems, &ui.NestedSubmenuItem{
+					LabeledSubmenuItem: ui.LabeledSubmenuItem{
+						Label:                libts.PeerName(peer),
+						AdditionalLabel:      formatKeyExpiry(remaining),
+						AdditionalLabelColor: keyExpiryColor(remaining),
+					},
+					Submenu: m.peerDetailsSubmenu(peer),
+				})
+			}
+			if len(expiringItems) > 0 {
+				networkNodes = append(networkNodes,
+					&ui.TitleSubmenuItem{Label: "Expiring Keys"})
+				networkNodes = append(networkNodes, expiringItems...)
+				networkNodes = append(networkNodes,
+					&ui.SpacerSubmenuItem{})
+			}
+
+			isFirstGroup := true
+			for _, group := range m.groupPeers(arrangedPeers, m.devicesGroup) {
+				if len(group.peers) == 0 && !group.showEmpty {
+					continue
+				}
+
+				if !isFirstGroup {
+					networkNodes = append(networkNodes,
+						&ui.SpacerSubmenuItem{})
+				}
+				isFirstGroup = false
+
+				networkNodes = append(networkNodes,
+					m.buildNetworkDevicesSubmenuSection(group.title, group.peers)...)
+			}
+
+			visibleCount := len(arrangedPeers)
+
+			relayedCount := 0
+			for _, peer := range m.state.Peers {
+				if libts.GetPeerPath(peer, m.pings[peer.ID]).IsRelayed {
+					relayedCount++
+				}
+			}
+
+			m.networkDevices.AdditionalLabel = fmt.Sprintf("%d visible", visibleCount)
+			if relayedCount > 0 {
+				m.networkDevices.AdditionalLabel += fmt.Sprintf(", %d relayed", relayedCount)
+			}
+			m.networkDevices.Submenu.SetItems(networkNodes)
+		}
+
+		// Update the traffic submenu.
+		{
+			submenuItems := []ui.SubmenuItem{
+				ui.NewSettingsSubmenuItem("Sort By",
+					[]string{"Rate", "Total", "Name"},
+					m.trafficSort,
+					func(newLabel string) tea.Msg {
+						return trafficSortMsg(newLabel)
+					},
+				),
+
+				&ui.SpacerSubmenuItem{},
+				&ui.ChartSubmenuItem{
+					Label:           "Download",
+					AdditionalLabel: "peak " + ui.FormatRate(maxRate(m.trafficStats.RxHistory)),
+					Values:          m.trafficStats.RxHistory,
+					Height:          3,
+					Color:           ui.Green,
+				},
+				&ui.ChartSubmenuItem{
+					Label:           "Upload",
+					AdditionalLabel: "peak " + ui.FormatRate(maxRate(m.trafficStats.TxHistory)),
+					Values:          m.trafficStats.TxHistory,
+					Height:          3,
+					Color:           ui.Blue,
+				},
+
+				&ui.SpacerSubmenuItem{},
+				&ui.TitleSubmenuItem{Label: "Since tsui Started"},
+				&ui.LabeledSubmenuItem{
+					Label: formatRxTx(
+						ui.FormatBytes(m.trafficStats.SessionRxBytes),
+						ui.FormatBytes(m.trafficStats.SessionTxBytes),
+					),
+				},
+
+				&ui.SpacerSubmenuItem{},
+			}
+
+			if m.trafficSort == "Total" {
+				submenuItems = append(submenuItems, &ui.TitleSubmenuItem{Label: "Peers - Total Since Connected"})
+			} else {
+				submenuItems = append(submenuItems, &ui.TitleSubmenuItem{Label: "Peers - Current Rate"})
+			}
+
+			for _, traffic := range sortPeerTraffic(m.trafficStats.Peers, m.trafficSort) {
+				var label string
+				if m.trafficSort == "Total" {
+					label = formatRxTx(ui.FormatBytes(traffic.Peer.RxBytes), ui.FormatBytes(traffic.Peer.TxBytes))
+				} else {
+					label = formatRxTx(ui.FormatRate(traffic.RxRate), ui.FormatRate(traffic.TxRate))
+				}
+
+				submenuItems = append(submenuItems, &ui.LabeledSubmenuItem{
+					Label:           libts.PeerName(traffic.Peer),
+					AdditionalLabel: label,
+					IsDim:           traffic.RxRate+traffic.TxRate == 0,
+				})
+			}
+
+			m.traffic.AdditionalLabel = formatRxTx(
+				ui.FormatRate(m.trafficStats.RxRate),
+				ui.FormatRate(m.trafficStats.TxRate),
+			)
+			m.traffic.Submenu.SetItems(submenuItems)
+		}
+
+		// Update the diagnostics submenu.
+		{
+			runLabel := "[Run Netcheck]"
+			if m.isNetcheckRunning {
+				runLabel = "Running Netcheck..."
+			} else if m.netcheck != nil {
+				runLabel = "[Rerun Netcheck]"
+			}
+
+			submenuItems := []ui.SubmenuItem{
+				&ui.LabeledSubmenuItem{
+					Label:   runLabel,
+					Variant: ui.SubmenuItemVariantAccent,
+					OnActivate: func() tea.Msg {
+						return startNetcheckMsg{}
+					},
+					IsDim: m.isNetcheckRunning,
+				},
+			}
 
-			if m.state.LockKey != nil {
-				statusText := "Online"
-				if m.state.IsLockedOut {
-					statusText = "Locked Out"
+			if m.netcheck == nil {
+				m.diagnostics.AdditionalLabel = ""
+			} else {
+				report := m.netcheck
+
+				yesNo := func(v bool) string {
+					if v {
+						return "Yes"
+					}
+					return "No"
+				}
+
+				ipv6Label := yesNo(report.IPv6)
+				if !report.IPv6 && report.OSHasIPv6 {
+					ipv6Label = "No, but OS has support"
+				}
+
+				hardNATLabel := "Unknown"
+				if v, ok := report.MappingVariesByDestIP.Get(); ok {
+					hardNATLabel = yesNo(v)
+				}
+
+				portMappingLabel := "None"
+				if len(report.PortMapping) > 0 {
+					portMappingLabel = strings.Join(report.PortMapping, ", ")
 				}
 
 				submenuItems = append(submenuItems,
-					&ui.SpacerSubmenuItem{},
-					&ui.TitleSubmenuItem{Label: "Tailnet Lock: " + statusText},
 					&ui.LabeledSubmenuItem{
-						Label: m.state.LockKey.CLIString(),
+						Label: "[Copy Report]",
 						OnActivate: func() tea.Msg {
-							err := clipboard.WriteString(m.state.LockKey.CLIString())
+							err := clipboard.WriteString(report.String())
 							if err != nil {
 								return errorMsg(err)
 							}
-							return successMsg("Copied tailnet lock key to clipboard.")
+							return successMsg("Copied netcheck report to clipboard.")
 						},
 					},
-				)
-			}
 
-			submenuItems = append(submenuItems,
-				&ui.SpacerSubmenuItem{},
-				&ui.LabeledSubmenuItem{
-					Label:   "[Disconnect from Tailscale]",
-					Variant: ui.SubmenuItemVariantAccent,
-					OnActivate: func() tea.Msg {
-						err := libts.Down(ctx)
-						if err != nil {
-							return errorMsg(err)
-						}
-						return tipMsg("You can also simply press . to disconnect.")
+					&ui.SpacerSubmenuItem{},
+					&ui.TitleSubmenuItem{Label: "Connectivity - " + report.Time.Format(time.Kitchen)},
+					&ui.LabeledSubmenuItem{
+						Label:           "UDP",
+						AdditionalLabel: yesNo(report.UDP),
+						Variant:         dangerIf(!report.UDP),
 					},
-				},
-			)
+					&ui.LabeledSubmenuItem{
+						Label:           "IPv4",
+						AdditionalLabel: yesNo(report.IPv4),
+					},
+					&ui.LabeledSubmenuItem{
+						Label:           "IPv6",
+						AdditionalLabel: ipv6Label,
+					},
+					&ui.LabeledSubmenuItem{
+						Label:           "Hard NAT",
+						AdditionalLabel: hardNATLabel,
+						Variant:         dangerIf(hardNATLabel == "Yes"),
+					},
+					&ui.LabeledSubmenuItem{
+						Label:           "Port Mapping",
+						AdditionalLabel: portMappingLabel,
+					},
+				)
 
-			m.deviceInfo.Submenu.SetItems(submenuItems)
+				derpTitle := "DERP Latency"
+				if preferred := report.PreferredDERP(); preferred != nil {
+					derpTitle += " - Nearest: " + preferred.Name
+				}
+
+				submenuItems = append(submenuItems,
+					&ui.SpacerSubmenuItem{},
+					&ui.TitleSubmenuItem{Label: derpTitle},
+				)
+
+				for _, region := range report.DERPLatencies {
+					latencyLabel := "Unreachable"
+					if region.Latency != 0 {
+						latencyLabel = fmt.Sprintf("%.1fms", float64(region.Latency.Microseconds())/1000)
+					}
+
+					variant := ui.SubmenuItemVariantDefault
+					if region.IsPreferred {
+						variant = ui.SubmenuItemVariantAccent
+					}
+
+					submenuItems = append(submenuItems, &ui.LabeledSubmenuItem{
+						Label:           fmt.Sprintf("%-4s %s", region.Code, region.Name),
+						AdditionalLabel: latencyLabel,
+						Variant:         variant,
+						IsDim:           region.Latency == 0,
+					})
+				}
+
+				if report.UDP {
+					m.diagnostics.AdditionalLabel = "UDP OK"
+				} else {
+					m.diagnostics.AdditionalLabel = "No UDP"
+				}
+			}
+
+			m.diagnostics.Submenu.SetItems(submenuItems)
 		}
 
-		// Update the exit node submenu.
+		// Update the DNS submenu.
 		{
-			exitNodeItems := make([]ui.SubmenuItem, 2+len(m.state.ExitNodes))
-			exitNodeItems[0] = &ui.ToggleableSubmenuItem{
-				LabeledSubmenuItem: ui.LabeledSubmenuItem{
-					Label: "None",
-					OnActivate: func() tea.Msg {
-						err := libts.SetExitNode(ctx, nil)
-						if err != nil {
-							return errorMsg(err)
-						}
-						return updateState()
+			submenuItems := []ui.SubmenuItem{}
+
+			if m.state.Prefs != nil && !m.state.Prefs.CorpDNS {
+				submenuItems = append(submenuItems,
+					&ui.LabeledSubmenuItem{
+						Label:           "DNS settings are not in use",
+						AdditionalLabel: "see Settings",
+						Variant:         ui.SubmenuItemVariantDanger,
 					},
-				},
-				IsActive: m.state.CurrentExitNode == nil,
+					&ui.SpacerSubmenuItem{},
+				)
 			}
-			exitNodeItems[1] = &ui.DividerSubmenuItem{}
-			for i, exitNode := range m.state.ExitNodes {
-				// Offset for the "None" item and the divider.
-				i += 2
 
-				pingLabel := "???"
-				if !exitNode.Online {
-					pingLabel = "Offline"
-				} else if m.pings[exitNode.ID] != nil {
-					pingLabel = fmt.Sprintf("%dms", int(math.Round(m.pings[exitNode.ID].LatencySeconds*1000)))
+			if m.dnsErr != nil {
+				submenuItems = append(submenuItems,
+					&ui.TitleSubmenuItem{Label: "Can't Load DNS Configuration"},
+					&ui.LabeledSubmenuItem{
+						Label:   m.dnsErr.Error(),
+						Variant: ui.SubmenuItemVariantDanger,
+					},
+					&ui.SpacerSubmenuItem{},
+				)
+			}
+
+			if m.dnsStatus == nil {
+				if m.dnsErr == nil {
+					submenuItems = append(submenuItems,
+						&ui.TitleSubmenuItem{Label: "Loading DNS configuration..."},
+						&ui.SpacerSubmenuItem{},
+					)
 				}
+				m.dns.AdditionalLabel = ""
+			} else {
+				dnsStatus := m.dnsStatus
 
-				exitNodeItems[i] = &ui.ToggleableSubmenuItem{
-					LabeledSubmenuItem: ui.LabeledSubmenuItem{
-						Label:           libts.PeerName(exitNode),
-						AdditionalLabel: pingLabel,
-						OnActivate: func() tea.Msg {
-							err := libts.SetExitNode(ctx, exitNode)
-							if err != nil {
-								return errorMsg(err)
-							}
-							return updateState()
-						},
-						IsDim: !exitNode.Online,
-					},
-					IsActive: m.state.CurrentExitNode != nil && exitNode.ID == *m.state.CurrentExitNode,
+				magicDNSTitle := "MagicDNS: Off"
+				if dnsStatus.MagicDNSEnabled {
+					magicDNSTitle = "MagicDNS: On"
+				}
+				submenuItems = append(submenuItems,
+					&ui.TitleSubmenuItem{Label: magicDNSTitle},
+					copyableSubmenuItem(dnsStatus.MagicDNSSuffix, "suffix", dnsStatus.MagicDNSSuffix, "MagicDNS suffix"),
+					&ui.SpacerSubmenuItem{},
+					&ui.TitleSubmenuItem{Label: "Nameservers"},
+				)
+
+				if len(dnsStatus.Nameservers) == 0 {
+					submenuItems = append(submenuItems, &ui.DividerSubmenuItem{})
+				}
+				for _, nameserver := range dnsStatus.Nameservers {
+					submenuItems = append(submenuItems, copyableSubmenuItem(nameserver, "", nameserver, "nameserver address"))
+				}
+
+				submenuItems = append(submenuItems,
+					&ui.SpacerSubmenuItem{},
+					&ui.TitleSubmenuItem{Label: "Split DNS Routes"},
+				)
+				if len(dnsStatus.SplitDNSRoutes) == 0 {
+					submenuItems = append(submenuItems, &ui.DividerSubmenuItem{})
+				}
+				for _, route := range dnsStatus.SplitDNSRoutes {
+					nameservers := strings.Join(route.Nameservers, ", ")
+					if nameservers == "" {
+						nameservers = "Tailscale"
+					}
+					submenuItems = append(submenuItems, copyableSubmenuItem(route.Domain, nameservers, route.Domain, "domain"))
+				}
+
+				submenuItems = append(submenuItems,
+					&ui.SpacerSubmenuItem{},
+					&ui.TitleSubmenuItem{Label: "Search Domains"},
+				)
+				if len(dnsStatus.SearchDomains) == 0 {
+					submenuItems = append(submenuItems, &ui.DividerSubmenuItem{})
+				}
+				for _, domain := range dnsStatus.SearchDomains {
+					submenuItems = append(submenuItems, copyableSubmenuItem(domain, "", domain, "search domain"))
+				}
+
+				submenuItems = append(submenuItems, &ui.SpacerSubmenuItem{})
+
+				if dnsStatus.MagicDNSEnabled {
+					m.dns.AdditionalLabel = "MagicDNS"
+				} else {
+					m.dns.AdditionalLabel = ""
 				}
 			}
 
-			m.exitNodes.AdditionalLabel = m.state.CurrentExitNodeName
-			m.exitNodes.Submenu.SetItems(exitNodeItems)
+			// Queries are sent from this process through the local network stack, so they only
+			// test the right daemon when it's the one at the default socket on this computer.
+			submenuItems = append(submenuItems, &ui.TitleSubmenuItem{Label: "Resolver Test"})
+			if libts.Socket() != "" {
+				submenuItems = append(submenuItems, &ui.LabeledSubmenuItem{
+					Label:           "Unavailable",
+					AdditionalLabel: "only works with the local default tailscaled",
+					IsDim:           true,
+				})
+			} else {
+				submenuItems = append(submenuItems, m.dnsQueryInput)
+				if m.dnsQuery != nil {
+					if m.dnsQuery.err != nil {
+						submenuItems = append(submenuItems, &ui.LabeledSubmenuItem{
+							Label:   m.dnsQuery.err.Error(),
+							Variant: ui.SubmenuItemVariantDanger,
+						})
+					} else {
+						for _, answer := range m.dnsQuery.answers {
+							submenuItems = append(submenuItems, &ui.LabeledSubmenuItem{
+								Label:           answer,
+								AdditionalLabel: m.dnsQuery.name,
+								OnActivate: func() tea.Msg {
+									err := clipboard.WriteString(answer)
+									if err != nil {
+										return errorMsg(err)
+									}
+									return successMsg("Copied address to clipboard.")
+								},
+							})
+						}
+					}
+				}
+			}
+
+			m.dns.Submenu.SetItems(submenuItems)
 		}
 
-		// Update the network devices submenu.
+		// Update the whois submenu.
 		{
-			networkNodes := make([]ui.SubmenuItem, 0)
+			submenuItems := []ui.SubmenuItem{
+				m.whoisInput,
+			}
 
-			networkNodes = append(networkNodes,
-				buildNetworkDevicesSubmenuSection("My Devices", m.state.MyNodes)...)
-			networkNodes = append(networkNodes,
-				&ui.SpacerSubmenuItem{})
-			networkNodes = append(networkNodes,
-				buildNetworkDevicesSubmenuSection("Tagged Devices", m.state.TaggedNodes)...)
+			if m.whois != nil && m.whois.err != nil {
+				submenuItems = append(submenuItems, &ui.LabeledSubmenuItem{
+					Label:   m.whois.err.Error(),
+					Variant: ui.SubmenuItemVariantDanger,
+				})
+			} else if m.whois != nil {
+				resp := m.whois.resp
+				nodeName := strings.TrimSuffix(resp.Node.Name, ".")
 
-			for _, key := range m.state.OwnedNodeKeys {
-				if key == "" {
-					key = "<none>"
+				// Link to the peer's details if it's one we know about.
+				peerIndex := slices.IndexFunc(m.state.Peers, func(peer *ipnstate.PeerStatus) bool {
+					return peer.ID == resp.Node.StableID
+				})
+				if peerIndex != -1 {
+					peer := m.state.Peers[peerIndex]
+					submenuItems = append(submenuItems, &ui.NestedSubmenuItem{
+						LabeledSubmenuItem: ui.LabeledSubmenuItem{
+							Label:   "[Show Device Details]",
+							Variant: ui.SubmenuItemVariantAccent,
+						},
+						Submenu: m.peerDetailsSubmenu(peer),
+					})
 				}
 
-				networkNodes = append(networkNodes,
-					&ui.SpacerSubmenuItem{})
-				networkNodes = append(networkNodes,
-					buildNetworkDevicesSubmenuSection(key, m.state.OwnedNodes[key])...)
+				submenuItems = append(submenuItems,
+					&ui.SpacerSubmenuItem{},
+					&ui.TitleSubmenuItem{Label: "Machine - " + m.whois.addr},
+					copyableSubmenuItem(nodeName, "", nodeName, "full domain"),
+					copyableSubmenuItem("ID", string(resp.Node.StableID), string(resp.Node.StableID), "Tailscale node ID"),
+				)
+
+				for _, addr := range resp.Node.Addresses {
+					submenuItems = append(submenuItems,
+						copyableSubmenuItem(addr.Addr().String(), "", addr.Addr().String(), "IP address"))
+				}
+
+				if resp.Node.IsTagged() {
+					submenuItems = append(submenuItems,
+						&ui.SpacerSubmenuItem{},
+						&ui.TitleSubmenuItem{Label: "Tags"},
+					)
+					for _, tag := range resp.Node.Tags {
+						submenuItems = append(submenuItems, copyableSubmenuItem(tag, "", tag, "tag"))
+					}
+				} else if resp.UserProfile != nil {
+					submenuItems = append(submenuItems,
+						&ui.SpacerSubmenuItem{},
+						&ui.TitleSubmenuItem{Label: "Owner"},
+						copyableSubmenuItem(resp.UserProfile.LoginName, resp.UserProfile.DisplayName,
+							resp.UserProfile.LoginName, "login name"),
+					)
+				}
+
+				submenuItems = append(submenuItems,
+					&ui.SpacerSubmenuItem{},
+					&ui.TitleSubmenuItem{Label: "Capabilities"},
+				)
+				caps := whoIsCapabilities(resp)
+				if len(caps) == 0 {
+					submenuItems = append(submenuItems, &ui.DividerSubmenuItem{})
+				}
+				for _, capability := range caps {
+					submenuItems = append(submenuItems, copyableSubmenuItem(capability, "", capability, "capability"))
+				}
+			}
+
+			m.whoisMenu.Submenu.SetItems(submenuItems)
+		}
+
+		// Update the log submenu.
+		{
+			events := m.events.Events()
+			submenuItems := make([]ui.SubmenuItem, 0, len(events)+1)
+
+			if m.config.LogFile != "" {
+				submenuItems = append(submenuItems,
+					&ui.TitleSubmenuItem{Label: "Also writing to " + m.config.LogFile},
+				)
 			}
 
-			lenSum := len(m.state.MyNodes) + len(m.state.TaggedNodes)
-			for _, value := range m.state.OwnedNodes {
-				lenSum += len(value)
+			for _, event := range events {
+				label := event.Text
+				if event.Count > 1 {
+					label += fmt.Sprintf(" (x%d)", event.Count)
+				}
+
+				line := fmt.Sprintf("%s %s %s", event.Time.Format(time.RFC3339), event.Level, label)
+				item := copyableSubmenuItem(label, event.Time.Format(time.TimeOnly), line, "log entry")
+				if event.Level == eventlog.LevelError {
+					item.Variant = ui.SubmenuItemVariantDanger
+				}
+
+				submenuItems = append(submenuItems, item)
 			}
 
-			m.networkDevices.AdditionalLabel = fmt.Sprintf("%d visible", lenSum)
-			m.networkDevices.Submenu.SetItems(networkNodes)
+			m.logMenu.Submenu.SetItems(submenuItems)
 		}
 
 		// Update the settings submenu.
@@ -325,7 +1443,123 @@ func (m *model) updateMenus() {
 						})
 					},
 				),
+			}
+
+			// Each of these prefs was added in a different Tailscale release, and older daemons
+			// silently ignore prefs they don't know about.
+			caps := m.state.Capabilities
+			autoUpdateApply, _ := m.state.Prefs.AutoUpdate.Apply.Get()
+
+			submenuItems = append(submenuItems,
+				&ui.SpacerSubmenuItem{},
+				&ui.TitleSubmenuItem{Label: "Services"},
+
+				requireFeature(caps, libts.FeatureSSH,
+					ui.NewYesNoSettingsSubmenuItem("Run SSH Server",
+						m.state.Prefs.RunSSH,
+						func(newValue bool) tea.Msg {
+							return editPrefs(&ipn.MaskedPrefs{
+								Prefs: ipn.Prefs{
+									RunSSH: newValue,
+								},
+								RunSSHSet: true,
+							})
+						},
+					),
+				),
+
+				requireFeature(caps, libts.FeatureWebClient,
+					ui.NewYesNoSettingsSubmenuItem("Run Web Interface",
+						m.state.Prefs.RunWebClient,
+						func(newValue bool) tea.Msg {
+							return editPrefs(&ipn.MaskedPrefs{
+								Prefs: ipn.Prefs{
+									RunWebClient: newValue,
+								},
+								RunWebClientSet: true,
+							})
+						},
+					),
+				),
+
+				requireFeature(caps, libts.FeatureAppConnector,
+					ui.NewYesNoSettingsSubmenuItem("Advertise App Connector",
+						m.state.Prefs.AppConnector.Advertise,
+						func(newValue bool) tea.Msg {
+							return editPrefs(&ipn.MaskedPrefs{
+								Prefs: ipn.Prefs{
+									AppConnector: ipn.AppConnectorPrefs{
+										Advertise: newValue,
+									},
+								},
+								AppConnectorSet: true,
+							})
+						},
+					),
+				),
+
+				&ui.SpacerSubmenuItem{},
+				&ui.TitleSubmenuItem{Label: "Management"},
+
+				requireFeature(caps, libts.FeatureAutoUpdate,
+					ui.NewYesNoSettingsSubmenuItem("Check for Updates",
+						m.state.Prefs.AutoUpdate.Check,
+						func(newValue bool) tea.Msg {
+							// Automatic updates require checking for updates, so turn them
+							// off along with it.
+							return editPrefs(&ipn.MaskedPrefs{
+								Prefs: ipn.Prefs{
+									AutoUpdate: ipn.AutoUpdatePrefs{
+										Check: newValue,
+										Apply: opt.NewBool(false),
+									},
+								},
+								AutoUpdateSet: ipn.AutoUpdatePrefsMask{
+									CheckSet: true,
+									ApplySet: !newValue,
+								},
+							})
+						},
+					),
+				),
+
+				requireFeature(caps, libts.FeatureAutoUpdate,
+					ui.NewYesNoSettingsSubmenuItem("Install Updates Automatically",
+						autoUpdateApply,
+						func(newValue bool) tea.Msg {
+							// Likewise, automatic updates turn on checking for updates.
+							return editPrefs(&ipn.MaskedPrefs{
+								Prefs: ipn.Prefs{
+									AutoUpdate: ipn.AutoUpdatePrefs{
+										Check: true,
+										Apply: opt.NewBool(newValue),
+									},
+								},
+								AutoUpdateSet: ipn.AutoUpdatePrefsMask{
+									CheckSet: newValue,
+									ApplySet: true,
+								},
+							})
+						},
+					),
+				),
 
+				requireFeature(caps, libts.FeaturePostureChecking,
+					ui.NewYesNoSettingsSubmenuItem("Allow Posture Checking",
+						m.state.Prefs.PostureChecking,
+						func(newValue bool) tea.Msg {
+							return editPrefs(&ipn.MaskedPrefs{
+								Prefs: ipn.Prefs{
+									PostureChecking: newValue,
+								},
+								PostureCheckingSet: true,
+							})
+						},
+					),
+				),
+			)
+
+			submenuItems = append(submenuItems,
 				&ui.SpacerSubmenuItem{},
 				&ui.TitleSubmenuItem{Label: accountTitle},
 
@@ -346,7 +1580,7 @@ func (m *model) updateMenus() {
 						return successMsg("Logged out.")
 					},
 				},
-			}
+			)
 
 			// On Linux, show the advanced Linux settings.
 			if runtime.GOOS == "linux" {
@@ -389,17 +1623,48 @@ func (m *model) updateMenus() {
 						},
 					),
 
-					ui.NewYesNoSettingsSubmenuItem("Enable Stateful Filtering",
-						!noStatefulFiltering,
+					requireFeature(caps, libts.FeatureStatefulFiltering,
+						ui.NewYesNoSettingsSubmenuItem("Enable Stateful Filtering",
+							!noStatefulFiltering,
+							func(newValue bool) tea.Msg {
+								return editPrefs(&ipn.MaskedPrefs{
+									Prefs: ipn.Prefs{
+										NoStatefulFiltering: opt.NewBool(!newValue),
+									},
+									NoStatefulFilteringSet: true,
+								})
+							},
+						),
+					),
+				)
+			}
+
+			// If we can control the tailscaled service, show its controls.
+			if m.hasService() {
+				manager := m.serviceManager
+
+				submenuItems = append(submenuItems,
+					&ui.SpacerSubmenuItem{},
+					&ui.TitleSubmenuItem{Label: "Service"},
+
+					ui.NewYesNoSettingsSubmenuItem("Start at Boot",
+						m.serviceStatus.IsEnabled(),
 						func(newValue bool) tea.Msg {
-							return editPrefs(&ipn.MaskedPrefs{
-								Prefs: ipn.Prefs{
-									NoStatefulFiltering: opt.NewBool(!newValue),
-								},
-								NoStatefulFilteringSet: true,
-							})
+							if newValue {
+								return runServiceAction(manager, manager.Enable)
+							}
+							return runServiceAction(manager, manager.Disable)
 						},
 					),
+
+					&ui.LabeledSubmenuItem{
+						Label:           "[Restart tailscaled]",
+						AdditionalLabel: m.serviceStatus.String(),
+						Variant:         ui.SubmenuItemVariantDanger,
+						OnActivate: func() tea.Msg {
+							return runServiceAction(manager, manager.Restart)
+						},
+					},
 				)
 			}
 
@@ -411,6 +1676,11 @@ func (m *model) updateMenus() {
 			m.deviceInfo,
 			m.exitNodes,
 			m.networkDevices,
+			m.traffic,
+			m.diagnostics,
+			m.dns,
+			m.whoisMenu,
+			m.logMenu,
 			m.settings,
 		})
 	} else {
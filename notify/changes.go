@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/neuralinkcorp/tsui/config"
+	"github.com/neuralinkcorp/tsui/libts"
+	"github.com/neuralinkcorp/tsui/ui"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+)
+
+// Find a peer by ID, or nil if it's not in the list.
+func findPeer(peers []*ipnstate.PeerStatus, id tailcfg.StableNodeID) *ipnstate.PeerStatus {
+	for _, peer := range peers {
+		if peer.ID == id {
+			return peer
+		}
+	}
+	return nil
+}
+
+// Returns true if the local node's key expires within warning of now.
+func keyExpiresSoon(state libts.State, warning time.Duration, now time.Time) bool {
+	remaining, ok := libts.KeyExpiresIn(state.Self, now)
+	return ok && remaining < warning
+}
+
+// Compare two successive states and get notifications for the important changes between
+// them, skipping any events turned off in cfg. keyExpiryWarning is how long before the key
+// expires to notify, and now is the time the new state was fetched.
+func StateChanges(oldState libts.State, newState libts.State, cfg config.NotificationsConfig,
+	keyExpiryWarning time.Duration, now time.Time) []Notification {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	var notifications []Notification
+
+	if cfg.Disconnected && oldState.BackendState == ipn.Running && newState.BackendState != ipn.Running {
+		notifications = append(notifications, Notification{
+			Summary: "Tailscale disconnected",
+			Body:    "Tailscale is now " + newState.BackendState.String() + ".",
+			Urgency: UrgencyNormal,
+		})
+	}
+
+	// Check both states at the same instant so we notify once, when the key crosses the
+	// threshold.
+	if cfg.KeyExpiry && !keyExpiresSoon(oldState, keyExpiryWarning, now) && keyExpiresSoon(newState, keyExpiryWarning, now) {
+		// If tsui wasn't running while the key got close to expiry, it may have already expired.
+		if remaining, _ := libts.KeyExpiresIn(newState.Self, now); remaining > 0 {
+			notifications = append(notifications, Notification{
+				Summary: "Tailscale key expiring soon",
+				Body: "This device's key expires in " + ui.FormatDuration(remaining) +
+					". Reauthenticate to stay connected.",
+				Urgency: UrgencyNormal,
+			})
+		} else {
+			notifications = append(notifications, Notification{
+				Summary: "Tailscale key expired",
+				Body:    "This device's key has expired. Reauthenticate to reconnect.",
+				Urgency: UrgencyCritical,
+			})
+		}
+	}
+
+	if cfg.ExitNodeOffline && newState.CurrentExitNode != nil && oldState.CurrentExitNode != nil &&
+		*newState.CurrentExitNode == *oldState.CurrentExitNode {
+		oldPeer := findPeer(oldState.ExitNodes, *oldState.CurrentExitNode)
+		newPeer := findPeer(newState.ExitNodes, *newState.CurrentExitNode)
+
+		if oldPeer != nil && oldPeer.Online && (newPeer == nil || !newPeer.Online) {
+			notifications = append(notifications, Notification{
+				Summary: "Exit node offline",
+				Body:    "Exit node " + newState.CurrentExitNodeName + " went offline. Internet traffic may not work.",
+				Urgency: UrgencyCritical,
+			})
+		}
+	}
+
+	if cfg.LockedOut && !oldState.IsLockedOut && newState.IsLockedOut {
+		notifications = append(notifications, Notification{
+			Summary: "Locked out by tailnet lock",
+			Body:    "This device needs to be signed by a trusted tailnet lock key before it can connect.",
+			Urgency: UrgencyCritical,
+		})
+	}
+
+	return notifications
+}
+
+// Get a notification for losing contact with tailscaled while Tailscale was running, unless
+// it's turned off in cfg. lastState is the last state fetched before the daemon became
+// unreachable.
+func DaemonUnreachable(lastState libts.State, err error, cfg config.NotificationsConfig) []Notification {
+	if !cfg.Enabled || !cfg.Disconnected || lastState.BackendState != ipn.Running {
+		return nil
+	}
+
+	return []Notification{{
+		Summary: "Can't reach Tailscale",
+		Body:    "tsui lost contact with tailscaled: " + err.Error(),
+		Urgency: UrgencyCritical,
+	}}
+}
@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/neuralinkcorp/tsui/config"
+	"github.com/neuralinkcorp/tsui/libts"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+)
+
+func TestStateChanges(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	cfg := config.Default().Notifications
+	cfg.Enabled = true
+	warning := 72 * time.Hour
+
+	expiresIn := func(d time.Duration) *ipnstate.PeerStatus {
+		expiry := now.Add(d)
+		return &ipnstate.PeerStatus{KeyExpiry: &expiry}
+	}
+
+	exitNodeID := tailcfg.StableNodeID("exit")
+	onlineExitNode := []*ipnstate.PeerStatus{{ID: exitNodeID, Online: true}}
+	offlineExitNode := []*ipnstate.PeerStatus{{ID: exitNodeID, Online: false}}
+
+	tests := []struct {
+		name     string
+		oldState libts.State
+		newState libts.State
+		want     []string
+	}{
+		{
+			name:     "still running",
+			oldState: libts.State{BackendState: ipn.Running},
+			newState: libts.State{BackendState: ipn.Running},
+		},
+		{
+			name:     "disconnected",
+			oldState: libts.State{BackendState: ipn.Running},
+			newState: libts.State{BackendState: ipn.Stopped},
+			want:     []string{"Tailscale disconnected"},
+		},
+		{
+			name:     "connected",
+			oldState: libts.State{BackendState: ipn.Starting},
+			newState: libts.State{BackendState: ipn.Running},
+		},
+		{
+			name:     "key crosses the warning threshold",
+			oldState: libts.State{BackendState: ipn.Running, Self: expiresIn(73 * time.Hour)},
+			newState: libts.State{BackendState: ipn.Running, Self: expiresIn(71 * time.Hour)},
+			want:     []string{"Tailscale key expiring soon"},
+		},
+		{
+			name:     "key expired while tsui wasn't running",
+			oldState: libts.State{BackendState: ipn.NoState},
+			newState: libts.State{BackendState: ipn.Running, Self: expiresIn(-3 * time.Hour)},
+			want:     []string{"Tailscale key expired"},
+		},
+		{
+			name:     "key was already expiring",
+			oldState: libts.State{BackendState: ipn.Running, Self: expiresIn(2 * time.Hour)},
+			newState: libts.State{BackendState: ipn.Running, Self: expiresIn(2 * time.Hour)},
+		},
+		{
+			name:     "key renewed",
+			oldState: libts.State{BackendState: ipn.Running, Self: expiresIn(2 * time.Hour)},
+			newState: libts.State{BackendState: ipn.Running, Self: expiresIn(180 * 24 * time.Hour)},
+		},
+		{
+			name:     "key never expires",
+			oldState: libts.State{BackendState: ipn.Running, Self: &ipnstate.PeerStatus{}},
+			newState: libts.State{BackendState: ipn.Running, Self: &ipnstate.PeerStatus{}},
+		},
+		{
+			name: "exit node went offline",
+			oldState: libts.State{BackendState: ipn.Running,
+				CurrentExitNode: &exitNodeID, ExitNodes: onlineExitNode},
+			newState: libts.State{BackendState: ipn.Running,
+				CurrentExitNode: &exitNodeID, ExitNodes: offlineExitNode},
+			want: []string{"Exit node offline"},
+		},
+		{
+			name: "exit node disappeared",
+			oldState: libts.State{BackendState: ipn.Running,
+				CurrentExitNode: &exitNodeID, ExitNodes: onlineExitNode},
+			newState: libts.State{BackendState: ipn.Running, CurrentExitNode: &exitNodeID},
+			want:     []string{"Exit node offline"},
+		},
+		{
+			name: "exit node was already offline",
+			oldState: libts.State{BackendState: ipn.Running,
+				CurrentExitNode: &exitNodeID, ExitNodes: offlineExitNode},
+			newState: libts.State{BackendState: ipn.Running,
+				CurrentExitNode: &exitNodeID, ExitNodes: offlineExitNode},
+		},
+		{
+			name: "stopped using the exit node",
+			oldState: libts.State{BackendState: ipn.Running,
+				CurrentExitNode: &exitNodeID, ExitNodes: onlineExitNode},
+			newState: libts.State{BackendState: ipn.Running, ExitNodes: offlineExitNode},
+		},
+		{
+			name:     "locked out",
+			oldState: libts.State{BackendState: ipn.Running},
+			newState: libts.State{BackendState: ipn.Stopped, IsLockedOut: true},
+			want:     []string{"Tailscale disconnected", "Locked out by tailnet lock"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, notification := range StateChanges(test.oldState, test.newState, cfg, warning, now) {
+				got = append(got, notification.Summary)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStateChangesConfig(t *testing.T) {
+	oldState := libts.State{BackendState: ipn.Running}
+	newState := libts.State{BackendState: ipn.Stopped, IsLockedOut: true}
+
+	// Notifications are off by default.
+	cfg := config.Default().Notifications
+	if got := StateChanges(oldState, newState, cfg, time.Hour, time.Now()); len(got) != 0 {
+		t.Errorf("got %d notifications with notifications off", len(got))
+	}
+
+	cfg.Enabled = true
+	cfg.Disconnected = false
+	got := StateChanges(oldState, newState, cfg, time.Hour, time.Now())
+	if len(got) != 1 || got[0].Summary != "Locked out by tailnet lock" {
+		t.Errorf("got %+v with disconnect notifications off, want only the lockout", got)
+	}
+}
+
+func TestStateChangesKeyExpiryBody(t *testing.T) {
+	now := time.Now()
+	cfg := config.Default().Notifications
+	cfg.Enabled = true
+
+	soon := now.Add(3 * time.Hour)
+	got := StateChanges(libts.State{}, libts.State{Self: &ipnstate.PeerStatus{KeyExpiry: &soon}}, cfg, 72*time.Hour, now)
+	if len(got) != 1 || got[0].Body != "This device's key expires in 3h. Reauthenticate to stay connected." {
+		t.Errorf("unexpected notifications for a key expiring soon: %+v", got)
+	}
+
+	// An expired key doesn't say it expires in a negative time.
+	expired := now.Add(-3 * time.Hour)
+	got = StateChanges(libts.State{}, libts.State{Self: &ipnstate.PeerStatus{KeyExpiry: &expired}}, cfg, 72*time.Hour, now)
+	if len(got) != 1 || got[0].Body != "This device's key has expired. Reauthenticate to reconnect." || got[0].Urgency != UrgencyCritical {
+		t.Errorf("unexpected notifications for an expired key: %+v", got)
+	}
+}
+
+func TestDaemonUnreachable(t *testing.T) {
+	cfg := config.Default().Notifications
+	cfg.Enabled = true
+	err := errors.New("connection refused")
+
+	got := DaemonUnreachable(libts.State{BackendState: ipn.Running}, err, cfg)
+	if len(got) != 1 || got[0].Summary != "Can't reach Tailscale" || got[0].Body != "tsui lost contact with tailscaled: connection refused" {
+		t.Errorf("unexpected notifications: %+v", got)
+	}
+
+	// Losing a daemon that wasn't connected doesn't disconnect anything.
+	if got := DaemonUnreachable(libts.State{BackendState: ipn.Stopped}, err, cfg); len(got) != 0 {
+		t.Errorf("got %+v while Tailscale was stopped", got)
+	}
+
+	cfg.Disconnected = false
+	if got := DaemonUnreachable(libts.State{BackendState: ipn.Running}, err, cfg); len(got) != 0 {
+		t.Errorf("got %+v with disconnect notifications off", got)
+	}
+}
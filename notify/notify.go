@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Urgency level of a notification, as defined by the freedesktop notification spec:
+//
+//	UrgencyLow, UrgencyNormal, UrgencyCritical
+type Urgency byte
+
+const (
+	UrgencyLow Urgency = iota
+	UrgencyNormal
+	UrgencyCritical
+)
+
+// A desktop notification.
+type Notification struct {
+	// Short title of the notification.
+	Summary string
+	// Longer description.
+	Body string
+	// How urgently the user needs to see it. Critical notifications usually don't time out.
+	Urgency Urgency
+}
+
+// Sends freedesktop notifications over the D-Bus session bus.
+type Notifier struct {
+	conn *dbus.Conn
+}
+
+// Connect to the session bus. Fails if there is no session bus, such as over SSH or on macOS.
+func New() (*Notifier, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to D-Bus session bus: %w", err)
+	}
+
+	return &Notifier{conn: conn}, nil
+}
+
+// Show a notification.
+func (n *Notifier) Send(notification Notification) error {
+	obj := n.conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+
+	call := obj.Call("org.freedesktop.Notifications.Notify", 0,
+		"tsui",    // app_name
+		uint32(0), // replaces_id
+		"",        // app_icon
+		notification.Summary,
+		notification.Body,
+		[]string{}, // actions
+		map[string]dbus.Variant{
+			"urgency": dbus.MakeVariant(byte(notification.Urgency)),
+		},
+		int32(-1), // expire_timeout, where -1 lets the server decide
+	)
+	if call.Err != nil {
+		return fmt.Errorf("cannot send notification: %w", call.Err)
+	}
+
+	return nil
+}
+
+// Disconnect from the session bus.
+func (n *Notifier) Close() error {
+	return n.conn.Close()
+}
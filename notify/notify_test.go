@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Start a private session bus for the test, returning its address. Skips the test if
+// dbus-daemon isn't installed.
+func startSessionBus(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address=1",
+		"--address=unix:dir="+t.TempDir())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("cannot read dbus-daemon address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// Fake notification server that records the notifications it's sent.
+type fakeServer struct {
+	received chan fakeNotification
+}
+
+type fakeNotification struct {
+	appName       string
+	summary       string
+	body          string
+	hints         map[string]dbus.Variant
+	expireTimeout int32
+}
+
+func (s *fakeServer) Notify(appName string, replacesID uint32, appIcon string, summary string, body string,
+	actions []string, hints map[string]dbus.Variant, expireTimeout int32) (uint32, *dbus.Error) {
+	s.received <- fakeNotification{appName, summary, body, hints, expireTimeout}
+	return 1, nil
+}
+
+func TestNotifierSend(t *testing.T) {
+	address := startSessionBus(t)
+
+	serverConn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverConn.Close()
+
+	server := &fakeServer{received: make(chan fakeNotification, 1)}
+	err = serverConn.Export(server, "/org/freedesktop/Notifications", "org.freedesktop.Notifications")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := serverConn.RequestName("org.freedesktop.Notifications", dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("cannot own the notifications name: %v %v", reply, err)
+	}
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := &Notifier{conn: conn}
+	defer notifier.Close()
+
+	err = notifier.Send(Notification{
+		Summary: "Exit node offline",
+		Body:    "Exit node foo went offline.",
+		Urgency: UrgencyCritical,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-server.received
+	if got.appName != "tsui" || got.summary != "Exit node offline" || got.body != "Exit node foo went offline." {
+		t.Errorf("unexpected notification %+v", got)
+	}
+	if urgency, _ := got.hints["urgency"].Value().(byte); urgency != byte(UrgencyCritical) {
+		t.Errorf("got urgency hint %v, want %d", got.hints["urgency"], UrgencyCritical)
+	}
+	if got.expireTimeout != -1 {
+		t.Errorf("got expire timeout %d, want -1", got.expireTimeout)
+	}
+}
+
+func TestNotifierSendWithoutServer(t *testing.T) {
+	conn, err := dbus.Connect(startSessionBus(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := &Notifier{conn: conn}
+	defer notifier.Close()
+
+	if err := notifier.Send(Notification{Summary: "Tailscale disconnected"}); err == nil {
+		t.Error("expected an error with no notification server on the bus")
+	}
+}
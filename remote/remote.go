@@ -0,0 +1,137 @@
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Default path of the tailscaled socket on Linux, where remote hosts most likely run.
+const DefaultSocket = "/var/run/tailscale/tailscaled.sock"
+
+// How long to wait for the forwarded socket to appear. Generous, since ssh may be waiting on
+// the user to type a password.
+const forwardTimeout = 2 * time.Minute
+
+// An SSH connection forwarding a remote tailscaled socket to a local one.
+type Forward struct {
+	// Path of the local end of the forwarded socket.
+	Socket string
+
+	host string
+	cmd  *exec.Cmd
+	dir  string
+	// Errors printed by ssh. Only safe to read once done is closed.
+	stderr bytes.Buffer
+	// Closed when ssh exits.
+	done chan struct{}
+	// Why ssh exited, valid once done is closed.
+	err error
+}
+
+// Connect to host (anything ssh accepts, like "user@host") and forward the tailscaled socket
+// at remoteSocket to a local socket. Blocks until the forward is ready, which may involve ssh
+// prompting the user on the terminal.
+func Start(host string, remoteSocket string) (*Forward, error) {
+	// ssh would take a host starting with "-" as an option, which can run arbitrary commands.
+	if host == "" || strings.HasPrefix(host, "-") {
+		return nil, fmt.Errorf("invalid SSH host %q", host)
+	}
+
+	dir, err := os.MkdirTemp("", "tsui-remote-")
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Forward{
+		Socket: filepath.Join(dir, "tailscaled.sock"),
+		host:   host,
+		dir:    dir,
+		done:   make(chan struct{}),
+	}
+
+	f.cmd = exec.Command("ssh",
+		"-N", // No remote command, just forwarding.
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "LogLevel=ERROR",
+		"-L", f.Socket+":"+remoteSocket,
+		"--",
+		host,
+	)
+	// Prompts go through the terminal directly, so only errors end up in stderr. Capture
+	// them, since they'd garble the UI.
+	f.cmd.Stderr = &f.stderr
+
+	err = f.cmd.Start()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("cannot start ssh: %w", err)
+	}
+
+	go func() {
+		f.err = f.cmd.Wait()
+		close(f.done)
+	}()
+
+	// ssh creates the local socket once it's connected and ready to forward.
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(forwardTimeout)
+
+	for {
+		if _, err := os.Stat(f.Socket); err == nil {
+			return f, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-f.done:
+			os.RemoveAll(dir)
+			if err := f.exitError(); err != nil {
+				return nil, fmt.Errorf("ssh to %s failed: %w", host, err)
+			}
+			return nil, errors.New("ssh exited before forwarding the tailscaled socket")
+		case <-timeout:
+			f.Close()
+			return nil, fmt.Errorf("timed out connecting to %s", host)
+		}
+	}
+}
+
+// Get a channel that's closed when ssh exits, such as when the connection drops.
+func (f *Forward) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get why the connection to the remote host was lost. Only valid once Done is closed.
+func (f *Forward) Err() error {
+	if err := f.exitError(); err != nil {
+		return fmt.Errorf("lost connection to %s: %w", f.host, err)
+	}
+	return fmt.Errorf("lost connection to %s", f.host)
+}
+
+// Describe why ssh exited, preferring its own error message. Returns nil if it didn't say.
+// Only valid once done is closed.
+func (f *Forward) exitError() error {
+	if message := strings.TrimSpace(f.stderr.String()); message != "" {
+		return errors.New(message)
+	}
+	return f.err
+}
+
+// Stop forwarding and clean up the local socket.
+func (f *Forward) Close() error {
+	select {
+	case <-f.done:
+	default:
+		f.cmd.Process.Kill()
+		<-f.done
+	}
+	return os.RemoveAll(f.dir)
+}
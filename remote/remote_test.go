@@ -0,0 +1,12 @@
+package remote
+
+import "testing"
+
+func TestStartInvalidHost(t *testing.T) {
+	for _, host := range []string{"", "-oProxyCommand=false", "-p2222"} {
+		if forward, err := Start(host, DefaultSocket); err == nil {
+			forward.Close()
+			t.Errorf("Start(%q) succeeded, want an error", host)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// Name of the tailscaled system service.
+const unitName = "tailscaled.service"
+
+// State of the tailscaled system service.
+type Status struct {
+	// Whether the service is installed at all.
+	Installed bool
+	// High-level state like "active", "inactive", or "failed".
+	ActiveState string
+	// More specific state like "running" or "dead".
+	SubState string
+	// Whether the service starts at boot, like "enabled" or "disabled".
+	UnitFileState string
+}
+
+// Returns true if the service is running (or starting up).
+func (s Status) IsActive() bool {
+	return s.ActiveState == "active" || s.ActiveState == "activating" || s.ActiveState == "reloading"
+}
+
+// Returns true if the service starts at boot.
+func (s Status) IsEnabled() bool {
+	return s.UnitFileState == "enabled"
+}
+
+// Describe the state like "inactive (dead)".
+func (s Status) String() string {
+	if !s.Installed {
+		return "not installed"
+	}
+	return fmt.Sprintf("%s (%s)", s.ActiveState, s.SubState)
+}
+
+// Controls the tailscaled system service. Actions that need more privileges than the user has
+// may prompt for authorization through polkit.
+type Manager interface {
+	// Get the current state of the service.
+	Status(ctx context.Context) (Status, error)
+	// Start the service if it isn't running.
+	Start(ctx context.Context) error
+	// Restart the service, or start it if it isn't running.
+	Restart(ctx context.Context) error
+	// Make the service start at boot.
+	Enable(ctx context.Context) error
+	// Stop the service from starting at boot.
+	Disable(ctx context.Context) error
+	// Release any resources held by the manager.
+	Close() error
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	systemdName    = "org.freedesktop.systemd1"
+	systemdPath    = "/org/freedesktop/systemd1"
+	systemdManager = "org.freedesktop.systemd1.Manager"
+	systemdUnit    = "org.freedesktop.systemd1.Unit"
+)
+
+// Manages tailscaled through systemd's D-Bus API.
+type systemd struct {
+	conn *dbus.Conn
+}
+
+// Connect to systemd on the system bus. Fails if the system doesn't use systemd.
+func ConnectSystemd() (Manager, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to D-Bus system bus: %w", err)
+	}
+
+	var hasSystemd bool
+	err = conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, systemdName).Store(&hasSystemd)
+	if err == nil && !hasSystemd {
+		err = errors.New("systemd is not running")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return NewSystemd(conn), nil
+}
+
+// Manage tailscaled through systemd on an existing bus connection. Useful for talking to a
+// fake systemd on a private bus.
+func NewSystemd(conn *dbus.Conn) Manager {
+	return &systemd{conn: conn}
+}
+
+// Call a method on systemd's manager object, allowing polkit to ask the user for
+// authorization.
+func (s *systemd) call(ctx context.Context, method string, args ...any) *dbus.Call {
+	return s.conn.Object(systemdName, systemdPath).
+		CallWithContext(ctx, systemdManager+"."+method, dbus.FlagAllowInteractiveAuthorization, args...)
+}
+
+func (s *systemd) Status(ctx context.Context) (Status, error) {
+	var unitPath dbus.ObjectPath
+	err := s.call(ctx, "LoadUnit", unitName).Store(&unitPath)
+	if err != nil {
+		return Status{}, err
+	}
+
+	unit := s.conn.Object(systemdName, unitPath)
+	properties := make(map[string]string)
+	for _, name := range []string{"LoadState", "ActiveState", "SubState", "UnitFileState"} {
+		value, err := unit.GetProperty(systemdUnit + "." + name)
+		if err != nil {
+			return Status{}, err
+		}
+		properties[name], _ = value.Value().(string)
+	}
+
+	return Status{
+		Installed:     properties["LoadState"] != "not-found",
+		ActiveState:   properties["ActiveState"],
+		SubState:      properties["SubState"],
+		UnitFileState: properties["UnitFileState"],
+	}, nil
+}
+
+func (s *systemd) Start(ctx context.Context) error {
+	return s.call(ctx, "StartUnit", unitName, "replace").Err
+}
+
+func (s *systemd) Restart(ctx context.Context) error {
+	return s.call(ctx, "RestartUnit", unitName, "replace").Err
+}
+
+func (s *systemd) Enable(ctx context.Context) error {
+	err := s.call(ctx, "EnableUnitFiles", []string{unitName}, false, false).Err
+	if err != nil {
+		return err
+	}
+	return s.call(ctx, "Reload").Err
+}
+
+func (s *systemd) Disable(ctx context.Context) error {
+	err := s.call(ctx, "DisableUnitFiles", []string{unitName}, false).Err
+	if err != nil {
+		return err
+	}
+	return s.call(ctx, "Reload").Err
+}
+
+func (s *systemd) Close() error {
+	return s.conn.Close()
+}
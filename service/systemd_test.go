@@ -0,0 +1,252 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// Path systemd gives tailscaled.service's unit object.
+const tailscaledUnitPath = dbus.ObjectPath("/org/freedesktop/systemd1/unit/tailscaled_2eservice")
+
+// Start a private session bus for the test, returning its address. Skips the test if
+// dbus-daemon isn't installed.
+func startSessionBus(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address=1",
+		"--address=unix:dir="+t.TempDir())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("cannot read dbus-daemon address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// Fake systemd manager object that records the methods called on it.
+type fakeSystemd struct {
+	mu    sync.Mutex
+	calls []string
+	// Method to fail with an access denied error, like polkit does when the user cancels.
+	deny string
+}
+
+// Change to a unit file, as returned by EnableUnitFiles and DisableUnitFiles.
+type unitFileChange struct {
+	Type        string
+	Filename    string
+	Destination string
+}
+
+// Record a call like "StartUnit tailscaled.service replace".
+func (f *fakeSystemd) record(method string, args ...any) *dbus.Error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	call := method
+	for _, arg := range args {
+		call += fmt.Sprint(" ", arg)
+	}
+	f.calls = append(f.calls, call)
+
+	if method == f.deny {
+		return dbus.NewError("org.freedesktop.DBus.Error.AccessDenied", []any{"Access denied"})
+	}
+	return nil
+}
+
+func (f *fakeSystemd) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+func (f *fakeSystemd) LoadUnit(name string) (dbus.ObjectPath, *dbus.Error) {
+	return tailscaledUnitPath, f.record("LoadUnit", name)
+}
+
+func (f *fakeSystemd) StartUnit(name string, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return "/org/freedesktop/systemd1/job/1", f.record("StartUnit", name, mode)
+}
+
+func (f *fakeSystemd) RestartUnit(name string, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return "/org/freedesktop/systemd1/job/2", f.record("RestartUnit", name, mode)
+}
+
+func (f *fakeSystemd) EnableUnitFiles(files []string, runtime bool, force bool) (bool, []unitFileChange, *dbus.Error) {
+	return false, []unitFileChange{}, f.record("EnableUnitFiles", files, runtime, force)
+}
+
+func (f *fakeSystemd) DisableUnitFiles(files []string, runtime bool) ([]unitFileChange, *dbus.Error) {
+	return []unitFileChange{}, f.record("DisableUnitFiles", files, runtime)
+}
+
+func (f *fakeSystemd) Reload() *dbus.Error {
+	return f.record("Reload")
+}
+
+// Run a fake systemd on a private bus, with tailscaled.service in the given state, and
+// connect a Manager to it.
+func startFakeSystemd(t *testing.T, fake *fakeSystemd, unitProperties map[string]string) Manager {
+	t.Helper()
+	address := startSessionBus(t)
+
+	serverConn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { serverConn.Close() })
+
+	err = serverConn.Export(fake, systemdPath, systemdManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := make(map[string]*prop.Prop)
+	for name, value := range unitProperties {
+		props[name] = &prop.Prop{Value: value, Emit: prop.EmitFalse}
+	}
+	_, err = prop.Export(serverConn, tailscaledUnitPath, prop.Map{systemdUnit: props})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := serverConn.RequestName(systemdName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("cannot own %s: %v %v", systemdName, reply, err)
+	}
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := NewSystemd(conn)
+	t.Cleanup(func() { manager.Close() })
+
+	return manager
+}
+
+func TestSystemdStatus(t *testing.T) {
+	fake := &fakeSystemd{}
+	manager := startFakeSystemd(t, fake, map[string]string{
+		"LoadState":     "loaded",
+		"ActiveState":   "failed",
+		"SubState":      "failed",
+		"UnitFileState": "enabled",
+	})
+
+	status, err := manager.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Status{Installed: true, ActiveState: "failed", SubState: "failed", UnitFileState: "enabled"}
+	if status != want {
+		t.Errorf("got %+v, want %+v", status, want)
+	}
+	if status.IsActive() || !status.IsEnabled() {
+		t.Errorf("%v should be enabled but not active", status)
+	}
+	if got, want := fake.recorded(), []string{"LoadUnit tailscaled.service"}; !slices.Equal(got, want) {
+		t.Errorf("got calls %q, want %q", got, want)
+	}
+}
+
+func TestSystemdStatusNotInstalled(t *testing.T) {
+	// systemd still loads a unit that doesn't exist, and reports it as not found.
+	manager := startFakeSystemd(t, &fakeSystemd{}, map[string]string{
+		"LoadState":     "not-found",
+		"ActiveState":   "inactive",
+		"SubState":      "dead",
+		"UnitFileState": "",
+	})
+
+	status, err := manager.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Installed || status.String() != "not installed" {
+		t.Errorf("got %+v (%s), want not installed", status, status)
+	}
+}
+
+func TestSystemdActions(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(Manager) error
+		want   []string
+	}{
+		{
+			name:   "start",
+			action: func(m Manager) error { return m.Start(context.Background()) },
+			want:   []string{"StartUnit tailscaled.service replace"},
+		},
+		{
+			name:   "restart",
+			action: func(m Manager) error { return m.Restart(context.Background()) },
+			want:   []string{"RestartUnit tailscaled.service replace"},
+		},
+		{
+			name:   "enable",
+			action: func(m Manager) error { return m.Enable(context.Background()) },
+			want:   []string{"EnableUnitFiles [tailscaled.service] false false", "Reload"},
+		},
+		{
+			name:   "disable",
+			action: func(m Manager) error { return m.Disable(context.Background()) },
+			want:   []string{"DisableUnitFiles [tailscaled.service] false", "Reload"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeSystemd{}
+			manager := startFakeSystemd(t, fake, nil)
+
+			if err := test.action(manager); err != nil {
+				t.Fatal(err)
+			}
+			if got := fake.recorded(); !slices.Equal(got, test.want) {
+				t.Errorf("got calls %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSystemdEnableDenied(t *testing.T) {
+	fake := &fakeSystemd{deny: "EnableUnitFiles"}
+	manager := startFakeSystemd(t, fake, nil)
+
+	err := manager.Enable(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Access denied") {
+		t.Errorf("got error %v, want access denied", err)
+	}
+	// Nothing changed, so there's nothing to reload.
+	if got, want := fake.recorded(), []string{"EnableUnitFiles [tailscaled.service] false false"}; !slices.Equal(got, want) {
+		t.Errorf("got calls %q, want %q", got, want)
+	}
+}
@@ -3,13 +3,22 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"runtime"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/neuralinkcorp/tsui/config"
+	"github.com/neuralinkcorp/tsui/eventlog"
+	"github.com/neuralinkcorp/tsui/export"
+	"github.com/neuralinkcorp/tsui/favorites"
 	"github.com/neuralinkcorp/tsui/libts"
+	"github.com/neuralinkcorp/tsui/notify"
+	"github.com/neuralinkcorp/tsui/remote"
+	"github.com/neuralinkcorp/tsui/service"
 	"github.com/neuralinkcorp/tsui/ui"
 	"github.com/neuralinkcorp/tsui/version"
 	"tailscale.com/ipn/ipnstate"
@@ -24,15 +33,40 @@ const (
 	// Rate at which to poll Tailscale for status updates.
 	tickInterval = 3 * time.Second
 
+	// Bounds of the exponential backoff when retrying an unreachable daemon.
+	minRetryDelay = 1 * time.Second
+	maxRetryDelay = 30 * time.Second
+
+	// Rate at which to refresh the DNS configuration, which rarely changes.
+	dnsTickInterval = 30 * time.Second
+	// Timeout for resolver test queries.
+	dnsQueryTimeout = 5 * time.Second
+
 	// Rate at which to gather latency from peers.
 	pingTickInterval = 6 * time.Second
 	// Per-peer ping timeout.
 	pingTimeout = 1 * time.Second
+	// Number of peers to ping at once.
+	maxConcurrentPings = 8
+
+	// How long to wait for an interactive login to complete.
+	loginTimeout = 5 * time.Minute
+	// Rate at which to poll Tailscale while waiting for a login.
+	loginTickInterval = 1 * time.Second
+
+	// Timeout for a whois lookup.
+	whoisTimeout = 5 * time.Second
+
+	// How long to wait for tailscaled to rerun its netcheck before showing its previous result.
+	netcheckTimeout = 10 * time.Second
 
 	// How long to keep messages in the bottom bar.
 	errorLifetime   = 6 * time.Second
 	successLifetime = 3 * time.Second
 	tipLifetime     = 3 * time.Second
+
+	// Number of events to keep in the event log.
+	eventLogSize = 500
 )
 
 // The type of the bottom bar status message:
@@ -50,20 +84,97 @@ var ctx = context.Background()
 
 // Central model containing application state.
 type model struct {
-	// Current Tailscale state info.
+	// User configuration.
+	config *config.Config
+	// Host whose tailscaled we're managing over SSH, or an empty string if it's local.
+	remoteHost string
+	// SSH connection forwarding the remote tailscaled socket, or nil if it's local.
+	remoteForward *remote.Forward
+	// Error to exit with after the UI quits, or nil to exit normally.
+	exitErr error
+	// History of status messages, state changes, and errors.
+	events *eventlog.Log
+	// Desktop notifier or nil if notifications are off or unavailable.
+	notifier *notify.Notifier
+	// The user's favorite peers.
+	favorites *favorites.Favorites
+	// Non-fatal error from starting up, shown in the status bar once the UI starts.
+	startupErr error
+	// Controls the tailscaled system service, or nil if unavailable.
+	serviceManager service.Manager
+	// Last known state of the tailscaled system service, or nil if unknown.
+	serviceStatus *service.Status
+
+	// Current Tailscale state info. If the daemon is unreachable, this is the last known state.
 	state libts.State
+	// Why the daemon is unreachable, or nil if it's reachable.
+	daemonErr error
+	// Delay before the next attempt to reach the daemon, which doubles on each failure.
+	retryDelay time.Duration
+	// Time of the next attempt to reach the daemon.
+	retryAt time.Time
+	// Current "generation" number for retries, like statusGen.
+	retryGen int
 	// Ping results per peer.
 	pings map[tailcfg.StableNodeID]*ipnstate.PingResult
+	// Whether a batch of pings is running. Ticks are skipped until it finishes.
+	isPinging bool
 	// Whether the user has write permissions to the Tailscale config.
 	canWrite bool
+	// Time the in-progress interactive login times out, or zero if there isn't one.
+	loginDeadline time.Time
+	// Whether the in-progress interactive login has gotten an auth URL yet.
+	loginHasAuthURL bool
+	// Per-peer traffic rates and totals since tsui started.
+	trafficStats *libts.TrafficTracker
+	// How to sort peers in the traffic submenu: "Rate", "Total", or "Name".
+	trafficSort string
+	// How to sort peers in the network devices submenu: "Name", "Online", "Last Seen",
+	// "Latency", or "Traffic".
+	devicesSort string
+	// How to group peers in the network devices submenu: "Owner", "OS", "Tag", or "Status".
+	devicesGroup string
+	// Whether to leave offline peers out of the network devices submenu.
+	hideOffline bool
+	// Which peers the network devices submenu exports.
+	exportFilter export.Filter
+	// Result of the last netcheck or nil if it hasn't been run.
+	netcheck *libts.NetcheckReport
+	// Whether a netcheck is currently running.
+	isNetcheckRunning bool
+	// Current DNS configuration or nil if it hasn't been fetched.
+	dnsStatus *libts.DNSStatus
+	// Error from the last attempt to fetch the DNS configuration, if it failed.
+	dnsErr error
+	// Result of the last resolver test or nil if none has been run.
+	dnsQuery *dnsQueryMsg
+	// Result of the last whois lookup or nil if none has been run.
+	whois *whoisMsg
 
 	// Main menu.
 	menu           ui.Appmenu
 	deviceInfo     *ui.AppmenuItem
 	exitNodes      *ui.AppmenuItem
 	networkDevices *ui.AppmenuItem
+	traffic        *ui.AppmenuItem
+	diagnostics    *ui.AppmenuItem
+	dns            *ui.AppmenuItem
+	whoisMenu      *ui.AppmenuItem
+	logMenu        *ui.AppmenuItem
 	settings       *ui.AppmenuItem
 
+	// Nested submenus with details of each peer. These persist across menu updates so they
+	// keep their cursor position.
+	peerDetails map[tailcfg.StableNodeID]*ui.Submenu
+	// "Copy As" submenus of each device, including this one, which persist for the same reason.
+	copyMenus map[tailcfg.StableNodeID]*ui.Submenu
+	// Submenu of export formats in the network devices submenu.
+	exportMenu *ui.Submenu
+
+	// Inputs persist across menu updates so they keep their contents.
+	dnsQueryInput *ui.InputSubmenuItem
+	whoisInput    *ui.InputSubmenuItem
+
 	// Current width of the terminal.
 	terminalWidth int
 	// Current height of the terminal.
@@ -86,7 +197,7 @@ type model struct {
 }
 
 // Initialize the application state.
-func initialModel() (model, error) {
+func initialModel(cfg *config.Config) (model, error) {
 	m := model{
 		// Main menu items.
 		deviceInfo: &ui.AppmenuItem{Label: "This Device"},
@@ -94,16 +205,76 @@ func initialModel() (model, error) {
 			Submenu: ui.Submenu{Exclusivity: ui.SubmenuExclusivityOne},
 		},
 		networkDevices: &ui.AppmenuItem{Label: "Network Devices"},
+		traffic:        &ui.AppmenuItem{Label: "Traffic"},
+		diagnostics:    &ui.AppmenuItem{Label: "Diagnostics"},
+		dns:            &ui.AppmenuItem{Label: "DNS"},
+		whoisMenu:      &ui.AppmenuItem{Label: "Whois"},
+		logMenu:        &ui.AppmenuItem{Label: "Log"},
 		settings:       &ui.AppmenuItem{Label: "Settings"},
+
+		peerDetails: make(map[tailcfg.StableNodeID]*ui.Submenu),
+		copyMenus:   make(map[tailcfg.StableNodeID]*ui.Submenu),
+		exportMenu:  &ui.Submenu{},
+
+		// Init runs the first batch of pings.
+		isPinging: true,
+
+		trafficStats: libts.NewTrafficTracker(),
+		trafficSort:  "Rate",
+		devicesSort:  "Name",
+		devicesGroup: "Owner",
 	}
 
-	state, err := libts.GetState(ctx)
+	m.dnsQueryInput = &ui.InputSubmenuItem{
+		Label:       "Resolve",
+		Placeholder: "enter a name",
+		OnSubmit:    queryDNS,
+	}
+	m.whoisInput = &ui.InputSubmenuItem{
+		Label:       "Address",
+		Placeholder: "100.x.y.z[:port]",
+		OnSubmit:    lookupWhoIs,
+	}
+
+	m.config = cfg
+
+	var err error
+	m.events, err = eventlog.New(eventLogSize, cfg.LogFile)
 	if err != nil {
 		return m, err
 	}
 
+	// A broken favorites file shouldn't stop tsui from starting, so start without favorites.
+	m.favorites, m.startupErr = favorites.Load()
+
+	if cfg.Notifications.Enabled {
+		// Notifications are nice to have, so don't refuse to start without them.
+		m.notifier, err = notify.New()
+		if err != nil {
+			m.events.Add(eventlog.LevelError, "Desktop notifications unavailable: "+err.Error())
+		}
+	}
+
+	// Controlling the service only makes sense for the local tailscaled at the default socket.
+	if runtime.GOOS == "linux" && libts.Socket() == "" {
+		m.serviceManager, err = service.ConnectSystemd()
+		if err != nil {
+			m.events.Add(eventlog.LevelInfo, "Service control unavailable: "+err.Error())
+		}
+	}
+
+	// If the daemon is unreachable, start anyway. The first update will fail and start
+	// retrying until it comes back.
+	state, err := libts.GetState(ctx)
+	if err != nil {
+		m.events.Add(eventlog.LevelInfo, "Started tsui; Tailscale is unreachable")
+		return m, nil
+	}
+	m.events.Add(eventlog.LevelInfo, "Started tsui; Tailscale is "+state.BackendState.String())
+
 	m.canWrite = libts.CanWrite(ctx)
 	m.state = state
+	m.trafficStats.Update(state, time.Now())
 	m.updateMenus()
 
 	return m, nil
@@ -116,6 +287,10 @@ func (m model) Init() tea.Cmd {
 		updateState,
 		// Run an initial batch of pings.
 		makeDoPings(m.state.ExitNodes),
+		// Fetch the DNS configuration.
+		updateDNSStatus,
+		// Fetch the state of the tailscaled service.
+		makeUpdateServiceStatus(m.serviceManager),
 		// Kick off our ticks.
 		tea.Tick(tickInterval, func(_ time.Time) tea.Msg {
 			return tickMsg{}
@@ -123,11 +298,23 @@ func (m model) Init() tea.Cmd {
 		tea.Tick(pingTickInterval, func(_ time.Time) tea.Msg {
 			return pingTickMsg{}
 		}),
+		tea.Tick(dnsTickInterval, func(_ time.Time) tea.Msg {
+			return dnsTickMsg{}
+		}),
 		tea.Tick(ui.PoggersAnimationInterval, func(_ time.Time) tea.Msg {
 			return animationTickMsg{}
 		}),
 		// And fetch the latest version.
 		fetchLatestVersion,
+		// Quit if the connection to the remote host drops.
+		makeWatchRemote(m.remoteForward),
+		// Show any problems from starting up.
+		func() tea.Msg {
+			if m.startupErr != nil {
+				return errorMsg(m.startupErr)
+			}
+			return nil
+		},
 	)
 }
 
@@ -140,25 +327,82 @@ func mainError(err error) {
 }
 
 func main() {
-	m, err := initialModel()
+	err := run()
 	if err != nil {
 		mainError(err)
 	}
+}
+
+// Parse arguments and run tsui, returning once it exits.
+func run() error {
+	socket := flag.String("socket", "", "path of the tailscaled socket to connect to; with --remote, the path on the remote host")
+	remoteHost := flag.String("remote", "", "manage tailscaled on a remote host over SSH, like user@host")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	// The flag takes precedence over the config file.
+	if *socket != "" {
+		cfg.Socket = *socket
+	}
+
+	var forward *remote.Forward
+	if *remoteHost != "" {
+		remoteSocket := cfg.Socket
+		if remoteSocket == "" {
+			remoteSocket = remote.DefaultSocket
+		}
+
+		forward, err = remote.Start(*remoteHost, remoteSocket)
+		if err != nil {
+			return err
+		}
+		defer forward.Close()
+
+		libts.SetSocket(forward.Socket)
+	} else if cfg.Socket != "" {
+		libts.SetSocket(cfg.Socket)
+	}
+
+	// Non-interactive subcommands skip the UI entirely.
+	if flag.NArg() > 0 {
+		return runSubcommand(cfg, flag.Args())
+	}
+
+	m, err := initialModel(cfg)
+	if err != nil {
+		return err
+	}
+	m.remoteHost = *remoteHost
+	m.remoteForward = forward
 
 	// Enable "alternate screen" mode, a terminal convention designed for rendering
-	// full-screen, interactive UIs.
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	// full-screen, interactive UIs, and enable mouse clicks and scrolling.
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 
 	// Run the UI. This will return when the UI exits or errors.
 	finalModel, err := p.Run()
 	if err != nil {
-		mainError(err)
+		return err
 	}
 	if finalModel == nil {
 		// This sometimes happens when runtime panics occur.
-		mainError(errors.New("looks like tsui crashed :("))
+		return errors.New("looks like tsui crashed :(")
 	}
 	m = finalModel.(model)
+	m.events.Close()
+	if m.notifier != nil {
+		m.notifier.Close()
+	}
+	if m.serviceManager != nil {
+		m.serviceManager.Close()
+	}
+	if m.exitErr != nil {
+		return m.exitErr
+	}
 
 	if m.latestVersion != "" && Version != "local" && m.latestVersion != Version {
 		text := lipgloss.NewStyle().
@@ -173,4 +417,6 @@ func main() {
 			Render("\n    " + version.UpdateCommand)
 		fmt.Println(text)
 	}
+
+	return nil
 }
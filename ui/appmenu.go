@@ -17,7 +17,7 @@ type AppmenuItem struct {
 	Submenu Submenu
 }
 
-func (i *AppmenuItem) render(isSelected bool, isAnySubmenuOpen bool) string {
+func (i *AppmenuItem) render(isSelected bool, isAnySubmenuOpen bool, labelWidth int) string {
 	style := lipgloss.NewStyle()
 
 	if isSelected {
@@ -41,7 +41,7 @@ func (i *AppmenuItem) render(isSelected bool, isAnySubmenuOpen bool) string {
 		style.
 			Faint(true).
 			Render(i.AdditionalLabel),
-		35,
+		labelWidth,
 		style,
 	)
 	arrow := style.
@@ -59,34 +59,162 @@ type Appmenu struct {
 	cursor int
 	// Whether the selected submenu is open.
 	isOpen bool
+
+	// Screen regions of the menu items in the last render, for mouse hit testing.
+	regions []itemRegion
+	// Whether the last render used the stacked single-pane layout.
+	stacked bool
+	// Offset of the submenu in the last render.
+	submenuX int
+	submenuY int
 }
 
-// Render the menu to a string.
-func (appmenu *Appmenu) Render(height int) string {
+// Render the menu to a string that fits within the given dimensions.
+func (appmenu *Appmenu) Render(width int, height int) string {
+	appmenu.regions = appmenu.regions[:0]
 	if len(appmenu.items) == 0 {
 		return ""
 	}
 
+	layout := computeMenuLayout(width)
+	appmenu.stacked = layout.stacked
+	root := &appmenu.items[appmenu.cursor].Submenu
+	submenu := root.active()
+
+	// Nested submenus, and any submenu in the stacked layout, get a breadcrumb line on top
+	// so the user knows where they are.
+	crumbs := root.breadcrumbs()
+	if layout.stacked {
+		crumbs = append([]string{appmenu.items[appmenu.cursor].Label}, crumbs...)
+	}
+
+	renderSubmenu := func(width int) string {
+		if len(crumbs) == 0 || !appmenu.isOpen {
+			appmenu.submenuY = 0
+			return submenu.Render(appmenu.isOpen, width, height)
+		}
+
+		breadcrumb := lipgloss.NewStyle().
+			Faint(true).
+			Render(Truncate("< "+strings.Join(crumbs, " / "), width))
+
+		appmenu.submenuY = 1
+		return breadcrumb + "\n" + submenu.Render(appmenu.isOpen, width, height-1)
+	}
+
+	// In the stacked layout, an open submenu replaces the main menu entirely.
+	if layout.stacked && appmenu.isOpen {
+		appmenu.submenuX = 0
+		return renderSubmenu(layout.submenuWidth)
+	}
+
 	var s strings.Builder
 
 	for i, item := range appmenu.items {
 		if i > 0 {
 			s.WriteByte('\n')
 		}
-		s.WriteString(item.render(i == appmenu.cursor, appmenu.isOpen))
+
+		text := item.render(i == appmenu.cursor, appmenu.isOpen, layout.labelWidth)
+		s.WriteString(text)
+
+		appmenu.regions = append(appmenu.regions, itemRegion{
+			index:  i,
+			region: Region{Y: i, Width: lipgloss.Width(text), Height: 1},
+		})
+	}
+
+	if layout.stacked {
+		return s.String()
 	}
 
 	// Render the submenu to the right of the appmenu.
+	appmenu.submenuX = lipgloss.Width(s.String())
 	return lipgloss.JoinHorizontal(lipgloss.Top,
 		s.String(),
-		appmenu.items[appmenu.cursor].Submenu.Render(appmenu.isOpen, height))
+		renderSubmenu(layout.submenuWidth))
+}
+
+// Get the submenu that's visible and receives input, which is the innermost open nested
+// submenu of the selected item.
+func (appmenu *Appmenu) activeSubmenu() *Submenu {
+	return appmenu.items[appmenu.cursor].Submenu.active()
+}
+
+// Returns true if the submenu was visible in the last render.
+func (appmenu *Appmenu) isSubmenuVisible() bool {
+	return !appmenu.stacked || appmenu.isOpen
+}
+
+// Handle a click at the given point, relative to the last render. Clicking a menu item opens
+// its submenu, and clicking a submenu item selects and activates it.
+// Returns a bubbletea command that can be run asynchronously.
+func (appmenu *Appmenu) Click(x int, y int) tea.Cmd {
+	if len(appmenu.items) == 0 {
+		return nil
+	}
+
+	// Clicked a menu item.
+	if i := hitTest(appmenu.regions, x, y); i != -1 && i < len(appmenu.items) {
+		if i != appmenu.cursor && appmenu.isOpen {
+			appmenu.closeAll()
+		}
+		appmenu.cursor = i
+		appmenu.isOpen = true
+		return nil
+	}
+
+	if !appmenu.isSubmenuVisible() {
+		return nil
+	}
+
+	// Clicked the breadcrumb.
+	if y < appmenu.submenuY {
+		appmenu.CloseSubmenu()
+		return nil
+	}
+
+	// Clicked a submenu item.
+	if appmenu.activeSubmenu().CursorTo(x-appmenu.submenuX, y-appmenu.submenuY) {
+		appmenu.isOpen = true
+		return appmenu.activeSubmenu().Activate()
+	}
+
+	return nil
+}
+
+// Handle a scroll wheel movement at the given horizontal position, relative to the last render.
+// Scrolling over the submenu moves the submenu cursor, and scrolling over the menu moves the
+// menu cursor.
+func (appmenu *Appmenu) Scroll(x int, down bool) {
+	if len(appmenu.items) == 0 {
+		return
+	}
+
+	// In the stacked layout, only one menu is visible, so there's no ambiguity.
+	if !appmenu.stacked {
+		overSubmenu := x >= appmenu.submenuX
+		if overSubmenu && !appmenu.isOpen {
+			// Open the submenu first so its cursor becomes visible.
+			appmenu.isOpen = true
+			return
+		} else if !overSubmenu && appmenu.isOpen {
+			appmenu.closeAll()
+		}
+	}
+
+	if down {
+		appmenu.CursorDown()
+	} else {
+		appmenu.CursorUp()
+	}
 }
 
 // Move the cursor to the next selectable item in the currently active menu.
 func (appmenu *Appmenu) CursorDown() {
 	if appmenu.isOpen {
 		// Move the cursor in the submenu.
-		appmenu.items[appmenu.cursor].Submenu.CursorDown()
+		appmenu.activeSubmenu().CursorDown()
 	} else {
 		// Move the cursor in the appmenu.
 		if appmenu.cursor < len(appmenu.items)-1 {
@@ -99,7 +227,7 @@ func (appmenu *Appmenu) CursorDown() {
 func (appmenu *Appmenu) CursorUp() {
 	if appmenu.isOpen {
 		// Move the cursor in the submenu.
-		appmenu.items[appmenu.cursor].Submenu.CursorUp()
+		appmenu.activeSubmenu().CursorUp()
 	} else {
 		// Move the cursor in the appmenu.
 		if appmenu.cursor > 0 {
@@ -132,7 +260,7 @@ func (appmenu *Appmenu) clampCursor() {
 func (appmenu *Appmenu) Activate() tea.Cmd {
 	if appmenu.isOpen {
 		// Activate the item in the submenu.
-		return appmenu.items[appmenu.cursor].Submenu.Activate()
+		return appmenu.activeSubmenu().Activate()
 	} else if len(appmenu.items) > 0 {
 		// Open the submenu.
 		appmenu.isOpen = true
@@ -140,13 +268,41 @@ func (appmenu *Appmenu) Activate() tea.Cmd {
 	return nil
 }
 
+// Returns true if an item in the open submenu, like a text input, is capturing keyboard input.
+// While this is true, key presses should be passed to HandleKey.
+func (appmenu *Appmenu) IsEditing() bool {
+	if !appmenu.isOpen || len(appmenu.items) == 0 {
+		return false
+	}
+	return appmenu.activeSubmenu().editingItem() != nil
+}
+
+// Pass a key press to the item that's capturing keyboard input, if any.
+// Returns a bubbletea command that can be run asynchronously.
+func (appmenu *Appmenu) HandleKey(msg tea.KeyMsg) tea.Cmd {
+	if !appmenu.IsEditing() {
+		return nil
+	}
+	return appmenu.activeSubmenu().editingItem().handleKey(msg)
+}
+
 // Returns true if a submenu is currently open.
 func (appmenu *Appmenu) IsSubmenuOpen() bool {
 	return appmenu.isOpen
 }
 
-// Close the submenu.
+// Close the innermost open nested submenu, or the submenu itself if none are open.
 func (appmenu *Appmenu) CloseSubmenu() {
+	if appmenu.items[appmenu.cursor].Submenu.closeChild() {
+		return
+	}
+
 	appmenu.isOpen = false
 	appmenu.items[appmenu.cursor].Submenu.ResetCursor()
 }
+
+// Close the submenu along with any nested submenus.
+func (appmenu *Appmenu) closeAll() {
+	appmenu.items[appmenu.cursor].Submenu.closeAllChildren()
+	appmenu.CloseSubmenu()
+}
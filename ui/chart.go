@@ -0,0 +1,117 @@
+package ui
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Braille dot bits for the left and right columns of a character cell, from bottom to top.
+var (
+	brailleLeftDots  = [4]rune{0x40, 0x04, 0x02, 0x01}
+	brailleRightDots = [4]rune{0x80, 0x20, 0x10, 0x08}
+)
+
+// Render a bar chart of values using braille characters. Each character holds two values
+// side by side and four levels of height. If there are more values than fit, only the most
+// recent (last) ones are shown, and if there are fewer, the chart is right-aligned.
+// The chart is scaled so the largest value fills the full height.
+func RenderChart(values []float64, width int, height int) string {
+	if width <= 0 || height <= 0 {
+		return ""
+	}
+
+	if len(values) > width*2 {
+		values = values[len(values)-width*2:]
+	}
+
+	var maxValue float64
+	if len(values) > 0 {
+		maxValue = slices.Max(values)
+	}
+	levels := height * 4
+
+	cells := make([][]rune, height)
+	for row := range cells {
+		cells[row] = []rune(strings.Repeat("⠀", width))
+	}
+
+	offset := width*2 - len(values)
+	for i, value := range values {
+		col := offset + i
+
+		filled := 0
+		if maxValue > 0 {
+			// Round up so any nonzero value gets at least one dot.
+			filled = int(math.Ceil(value / maxValue * float64(levels)))
+		}
+
+		for level := 0; level < filled; level++ {
+			row := height - 1 - level/4
+			if col%2 == 0 {
+				cells[row][col/2] |= brailleLeftDots[level%4]
+			} else {
+				cells[row][col/2] |= brailleRightDots[level%4]
+			}
+		}
+	}
+
+	lines := make([]string, height)
+	for row, line := range cells {
+		lines[row] = string(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// A non-selectable submenu item displaying a chart that fills the submenu width.
+type ChartSubmenuItem struct {
+	// The text displayed above the chart.
+	Label string
+	// An extra label shown on the right side above the chart. Will be shown in a muted color.
+	AdditionalLabel string
+	// Values to chart, oldest first.
+	Values []float64
+	// Height of the chart in lines, not including the label.
+	Height int
+	// Color of the chart.
+	Color lipgloss.Color
+}
+
+func (i *ChartSubmenuItem) isSelectable() bool {
+	return false
+}
+
+func (i *ChartSubmenuItem) onActivate() tea.Cmd {
+	return nil
+}
+
+func (i *ChartSubmenuItem) clearActiveFlag() {}
+
+func (i *ChartSubmenuItem) render(isSelected bool, isSubmenuOpen bool, width int) string {
+	style := lipgloss.NewStyle().
+		PaddingRight(1).
+		PaddingLeft(2)
+	innerWidth := width - style.GetHorizontalPadding()
+
+	labelStyle := lipgloss.NewStyle()
+	chartStyle := lipgloss.NewStyle().
+		Foreground(i.Color)
+	if !isSubmenuOpen {
+		labelStyle = labelStyle.
+			Faint(true)
+		chartStyle = chartStyle.
+			Faint(true)
+	}
+
+	label := RenderSplit(
+		labelStyle.Render(i.Label),
+		labelStyle.Faint(true).Render(i.AdditionalLabel),
+		innerWidth,
+		labelStyle,
+	)
+
+	return style.Render(label + "\n" + chartStyle.Render(RenderChart(i.Values, innerWidth, i.Height)))
+}
@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// A submenu item that captures keyboard input while it's being edited.
+type editableSubmenuItem interface {
+	SubmenuItem
+	// Returns true if the item is currently capturing keyboard input.
+	isEditing() bool
+	// Handles a key press while editing. Returns a bubbletea command that can be
+	// run asynchronously.
+	handleKey(msg tea.KeyMsg) tea.Cmd
+}
+
+// A single-line text input. Activating it starts editing, enter submits, and esc cancels.
+//
+// Because it holds its own state across renders, the same instance should be reused when
+// rebuilding a submenu's items.
+type InputSubmenuItem struct {
+	// The text displayed before the input.
+	Label string
+	// Text shown in a muted color when the input is empty.
+	Placeholder string
+	// Current contents of the input.
+	Value string
+	// Callback when the user submits the input with enter.
+	OnSubmit func(value string) tea.Msg
+	// Whether the input is currently capturing keyboard input.
+	editing bool
+}
+
+func (item *InputSubmenuItem) isSelectable() bool {
+	return true
+}
+
+func (item *InputSubmenuItem) onActivate() tea.Cmd {
+	item.editing = true
+	return nil
+}
+
+func (item *InputSubmenuItem) clearActiveFlag() {}
+
+func (item *InputSubmenuItem) isEditing() bool {
+	return item.editing
+}
+
+func (item *InputSubmenuItem) handleKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEnter:
+		item.editing = false
+		value := strings.TrimSpace(item.Value)
+		if value == "" || item.OnSubmit == nil {
+			return nil
+		}
+		return func() tea.Msg {
+			return item.OnSubmit(value)
+		}
+
+	case tea.KeyEsc:
+		item.editing = false
+
+	case tea.KeyBackspace:
+		if len(item.Value) > 0 {
+			runes := []rune(item.Value)
+			item.Value = string(runes[:len(runes)-1])
+		}
+
+	case tea.KeyCtrlU:
+		item.Value = ""
+
+	case tea.KeyRunes, tea.KeySpace:
+		item.Value += string(msg.Runes)
+	}
+
+	return nil
+}
+
+func (item *InputSubmenuItem) render(isSelected bool, isSubmenuOpen bool, width int) string {
+	style := lipgloss.NewStyle().
+		PaddingRight(1).
+		PaddingLeft(2).
+		Width(width)
+	valueStyle := lipgloss.NewStyle()
+
+	if isSubmenuOpen {
+		if isSelected {
+			style = style.
+				Background(Secondary).
+				Foreground(Black)
+			valueStyle = valueStyle.
+				Bold(true)
+		}
+	} else {
+		style = style.
+			Faint(true)
+	}
+
+	value := item.Value
+	if item.editing {
+		value += "█"
+	} else if value == "" {
+		value = item.Placeholder
+		valueStyle = valueStyle.
+			Faint(true)
+	}
+
+	innerWidth := width - style.GetHorizontalPadding()
+	label := item.Label + ": "
+
+	// While editing, keep the end of the value visible since that's where the cursor is.
+	if item.editing {
+		runes := []rune(value)
+		if maxLen := innerWidth - lipgloss.Width(label); maxLen > 0 && len(runes) > maxLen {
+			value = "…" + string(runes[len(runes)-maxLen+1:])
+		}
+	}
+
+	return style.Render(Truncate(label+valueStyle.Render(value), innerWidth))
+}
@@ -0,0 +1,55 @@
+package ui
+
+const (
+	// Width of the main menu labels, not including the arrow.
+	appmenuLabelWidth = 35
+	// Width of the main menu labels in the compact layout for medium-sized terminals.
+	appmenuCompactLabelWidth = 24
+	// Width of the arrow to the right of each main menu label.
+	appmenuArrowWidth = 3
+
+	// Narrowest a submenu can be before we switch to a smaller layout.
+	minSubmenuWidth = 40
+	// Preferred width of a submenu on normal-sized terminals.
+	defaultSubmenuWidth = 45
+	// Widest a submenu will grow on wide terminals. Past this, things get hard to read.
+	maxSubmenuWidth = 80
+)
+
+// Computed dimensions of the main menu for a given terminal width.
+type menuLayout struct {
+	// Width of the main menu labels.
+	labelWidth int
+	// Width of the submenu.
+	submenuWidth int
+	// If true, the terminal is too narrow to fit the main menu and submenu side by side,
+	// so only one of them is shown at a time.
+	stacked bool
+}
+
+// Pick the layout that best fits the given width:
+//
+//   - Wide terminals show the full-size menu, and submenus grow to fill the extra space.
+//   - Medium terminals (like a classic 80 column SSH session) shrink the main menu.
+//   - Narrow terminals stack the menu and submenu into a single pane.
+func computeMenuLayout(width int) menuLayout {
+	if width >= appmenuLabelWidth+appmenuArrowWidth+defaultSubmenuWidth {
+		return menuLayout{
+			labelWidth:   appmenuLabelWidth,
+			submenuWidth: min(width-appmenuLabelWidth-appmenuArrowWidth, maxSubmenuWidth),
+		}
+	}
+
+	if width >= appmenuCompactLabelWidth+appmenuArrowWidth+minSubmenuWidth {
+		return menuLayout{
+			labelWidth:   appmenuCompactLabelWidth,
+			submenuWidth: width - appmenuCompactLabelWidth - appmenuArrowWidth,
+		}
+	}
+
+	return menuLayout{
+		labelWidth:   max(0, min(width-appmenuArrowWidth, appmenuLabelWidth)),
+		submenuWidth: min(width, maxSubmenuWidth),
+		stacked:      true,
+	}
+}
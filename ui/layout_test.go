@@ -0,0 +1,32 @@
+package ui
+
+import "testing"
+
+func TestComputeMenuLayout(t *testing.T) {
+	tests := []struct {
+		width int
+		want  menuLayout
+	}{
+		// Wide: the submenu grows with the terminal, up to its maximum.
+		{200, menuLayout{labelWidth: 35, submenuWidth: 80}},
+		{100, menuLayout{labelWidth: 35, submenuWidth: 62}},
+		{83, menuLayout{labelWidth: 35, submenuWidth: 45}},
+
+		// Medium: one column too narrow for the full menu, so it shrinks.
+		{82, menuLayout{labelWidth: 24, submenuWidth: 55}},
+		{80, menuLayout{labelWidth: 24, submenuWidth: 53}},
+		{67, menuLayout{labelWidth: 24, submenuWidth: 40}},
+
+		// Narrow: one pane at a time.
+		{66, menuLayout{labelWidth: 35, submenuWidth: 66, stacked: true}},
+		{20, menuLayout{labelWidth: 17, submenuWidth: 20, stacked: true}},
+		{2, menuLayout{labelWidth: 0, submenuWidth: 2, stacked: true}},
+		{0, menuLayout{labelWidth: 0, submenuWidth: 0, stacked: true}},
+	}
+
+	for _, test := range tests {
+		if got := computeMenuLayout(test.width); got != test.want {
+			t.Errorf("computeMenuLayout(%d) = %+v, want %+v", test.width, got, test.want)
+		}
+	}
+}
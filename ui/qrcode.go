@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/skip2/go-qrcode"
+)
+
+// Render text as a QR code using half-block characters, so each line of output holds two
+// rows of modules. Dark modules are drawn black on white, so it scans on any terminal theme.
+func RenderQRCode(text string) (string, error) {
+	code, err := qrcode.New(text, qrcode.Low)
+	if err != nil {
+		return "", err
+	}
+	bitmap := code.Bitmap()
+
+	style := lipgloss.NewStyle().
+		Foreground(Black).
+		Background(White)
+
+	var lines []string
+	for y := 0; y < len(bitmap); y += 2 {
+		var line strings.Builder
+		for x := range bitmap[y] {
+			top := bitmap[y][x]
+			bottom := y+1 < len(bitmap) && bitmap[y+1][x]
+
+			switch {
+			case top && bottom:
+				line.WriteRune('█')
+			case top:
+				line.WriteRune('▀')
+			case bottom:
+				line.WriteRune('▄')
+			default:
+				line.WriteRune(' ')
+			}
+		}
+		lines = append(lines, style.Render(line.String()))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
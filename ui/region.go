@@ -0,0 +1,31 @@
+package ui
+
+// A rectangular area of the screen occupied by a rendered element, used for mouse hit testing.
+// Coordinates are relative to the top-left corner of whatever was rendered.
+type Region struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Returns true if the given point is inside the region.
+func (r Region) Contains(x int, y int) bool {
+	return x >= r.X && x < r.X+r.Width && y >= r.Y && y < r.Y+r.Height
+}
+
+// A rendered item's index paired with the region it occupies.
+type itemRegion struct {
+	index  int
+	region Region
+}
+
+// Find the index of the item at the given point, or -1 if there is none.
+func hitTest(regions []itemRegion, x int, y int) int {
+	for _, r := range regions {
+		if r.region.Contains(x, y) {
+			return r.index
+		}
+	}
+	return -1
+}
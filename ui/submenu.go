@@ -18,12 +18,11 @@ type SubmenuItem interface {
 	onActivate() tea.Cmd
 	// If applicable, "un-toggles" the item.
 	clearActiveFlag()
-	// Renders the item. isSelected will always be false if isSelectable() returns false.
-	render(isSelected bool, isSubmenuOpen bool) string
+	// Renders the item to the given width. isSelected will always be false if isSelectable()
+	// returns false.
+	render(isSelected bool, isSubmenuOpen bool, width int) string
 }
 
-const submenuItemWidth = 45
-
 // Visual variant of a submenu item:
 //
 //	SubmenuItemVariantDefault, SubmenuItemVariantDanger
@@ -52,6 +51,8 @@ type LabeledSubmenuItem struct {
 	Label string
 	// An extra label shown on the right side. Will be shown in a muted color.
 	AdditionalLabel string
+	// Optional color for the additional label, used to draw attention to it.
+	AdditionalLabelColor lipgloss.Color
 	// Visual variant.
 	Variant SubmenuItemVariant
 	// Callback when the item is activated.
@@ -72,7 +73,7 @@ func (item *LabeledSubmenuItem) clearActiveFlag() {
 	// No-op because this item is not toggleable.
 }
 
-func (item *LabeledSubmenuItem) render(isSelected bool, isSubmenuOpen bool) string {
+func (item *LabeledSubmenuItem) render(isSelected bool, isSubmenuOpen bool, width int) string {
 	colorStyle := lipgloss.NewStyle()
 
 	if isSubmenuOpen {
@@ -108,20 +109,31 @@ func (item *LabeledSubmenuItem) render(isSelected bool, isSubmenuOpen bool) stri
 	outerStyle := colorStyle.
 		PaddingRight(1).
 		PaddingLeft(2).
-		Width(submenuItemWidth)
+		Width(width)
 
 	return outerStyle.Render(
 		RenderSplit(
 			colorStyle.Render(item.Label),
-			colorStyle.
-				Faint(true).
-				Render(item.AdditionalLabel),
-			submenuItemWidth-outerStyle.GetHorizontalPadding(),
+			item.renderAdditionalLabel(colorStyle, isSelected, isSubmenuOpen),
+			width-outerStyle.GetHorizontalPadding(),
 			colorStyle,
 		),
 	)
 }
 
+// Render the additional label using the item's color style as a base.
+func (item *LabeledSubmenuItem) renderAdditionalLabel(colorStyle lipgloss.Style, isSelected bool, isSubmenuOpen bool) string {
+	if item.AdditionalLabelColor != "" && isSubmenuOpen && !isSelected {
+		return colorStyle.
+			Foreground(item.AdditionalLabelColor).
+			Render(item.AdditionalLabel)
+	}
+
+	return colorStyle.
+		Faint(true).
+		Render(item.AdditionalLabel)
+}
+
 // A menu item with a label that can be toggled active or inactive.
 type ToggleableSubmenuItem struct {
 	LabeledSubmenuItem
@@ -141,7 +153,7 @@ func (item *ToggleableSubmenuItem) clearActiveFlag() {
 	item.IsActive = false
 }
 
-func (item *ToggleableSubmenuItem) render(isSelected bool, isSubmenuOpen bool) string {
+func (item *ToggleableSubmenuItem) render(isSelected bool, isSubmenuOpen bool, width int) string {
 	colorStyle := lipgloss.NewStyle()
 
 	if isSubmenuOpen {
@@ -185,20 +197,33 @@ func (item *ToggleableSubmenuItem) render(isSelected bool, isSubmenuOpen bool) s
 
 	outerStyle := colorStyle.
 		Padding(0, 1).
-		Width(submenuItemWidth)
+		Width(width)
 
 	return outerStyle.Render(
 		RenderSplit(
 			labelPrefix+item.Label,
-			colorStyle.
-				Faint(true).
-				Render(item.AdditionalLabel),
-			submenuItemWidth-outerStyle.GetHorizontalPadding(),
+			item.renderAdditionalLabel(colorStyle, isSelected, isSubmenuOpen),
+			width-outerStyle.GetHorizontalPadding(),
 			colorStyle,
 		),
 	)
 }
 
+// A menu item with a label that opens a nested submenu when activated.
+type NestedSubmenuItem struct {
+	LabeledSubmenuItem
+	// The submenu to open. Because it holds its own cursor state, the same instance should be
+	// reused when rebuilding the parent submenu's items.
+	Submenu *Submenu
+}
+
+func (item *NestedSubmenuItem) render(isSelected bool, isSubmenuOpen bool, width int) string {
+	// Render like a normal labeled item, with an arrow hinting that it opens a submenu.
+	labeled := item.LabeledSubmenuItem
+	labeled.AdditionalLabel = strings.TrimLeft(labeled.AdditionalLabel+" >", " ")
+	return labeled.render(isSelected, isSubmenuOpen, width)
+}
+
 // A submenu item for a "settings control" that can have multiple values and activated to switch between them.
 type SettingSubmenuItem struct {
 	// Name of this setting.
@@ -258,13 +283,13 @@ func (item *SettingSubmenuItem) onActivate() tea.Cmd {
 
 func (item *SettingSubmenuItem) clearActiveFlag() {}
 
-func (item *SettingSubmenuItem) render(isSelected bool, isSubmenuOpen bool) string {
+func (item *SettingSubmenuItem) render(isSelected bool, isSubmenuOpen bool, width int) string {
 	selectedLabel := item.options[item.selected]
 
 	style := lipgloss.NewStyle().
 		PaddingRight(1).
 		PaddingLeft(2).
-		Width(submenuItemWidth)
+		Width(width)
 	selectedLabelStyle := lipgloss.NewStyle()
 
 	if isSubmenuOpen {
@@ -300,7 +325,7 @@ func (item *SettingSubmenuItem) render(isSelected bool, isSubmenuOpen bool) stri
 		RenderSplit(
 			item.Label,
 			selectedLabelStyle.Render(selectedLabel),
-			submenuItemWidth-style.GetHorizontalPadding(),
+			width-style.GetHorizontalPadding(),
 			lipgloss.NewStyle(),
 		),
 	)
@@ -319,7 +344,7 @@ func (d *DividerSubmenuItem) onActivate() tea.Cmd {
 
 func (d *DividerSubmenuItem) clearActiveFlag() {}
 
-func (d *DividerSubmenuItem) render(isSelected bool, isSubmenuOpen bool) string {
+func (d *DividerSubmenuItem) render(isSelected bool, isSubmenuOpen bool, width int) string {
 	return lipgloss.NewStyle().
 		Faint(true).
 		Render("  --")
@@ -338,7 +363,7 @@ func (s *SpacerSubmenuItem) onActivate() tea.Cmd {
 
 func (s *SpacerSubmenuItem) clearActiveFlag() {}
 
-func (s *SpacerSubmenuItem) render(isSelected bool, isSubmenuOpen bool) string {
+func (s *SpacerSubmenuItem) render(isSelected bool, isSubmenuOpen bool, width int) string {
 	return ""
 }
 
@@ -358,11 +383,11 @@ func (i *TitleSubmenuItem) onActivate() tea.Cmd {
 
 func (i *TitleSubmenuItem) clearActiveFlag() {}
 
-func (i *TitleSubmenuItem) render(isSelected bool, isSubmenuOpen bool) string {
+func (i *TitleSubmenuItem) render(isSelected bool, isSubmenuOpen bool, width int) string {
 	return lipgloss.NewStyle().
 		Faint(true).
 		PaddingLeft(2).
-		Render(i.Label)
+		Render(Truncate(i.Label, width-2))
 }
 
 type SubmenuExclusivity int
@@ -381,6 +406,12 @@ type Submenu struct {
 	Exclusivity SubmenuExclusivity
 	items       []SubmenuItem
 	cursor      int
+	// Nested submenu that is currently open, if any.
+	child *Submenu
+	// Label of the item that opened the nested submenu.
+	childLabel string
+	// Screen regions of the items visible in the last render, for mouse hit testing.
+	regions []itemRegion
 }
 
 // A rendered submenu item and its computed layout info.
@@ -389,12 +420,17 @@ type ComputedSubmenuItem struct {
 	height int
 }
 
-// Render the submenu to a fixed-height string with scrolling.
-func (submenu *Submenu) Render(isSubmenuOpen bool, height int) string {
+// Render the submenu to a fixed-width, fixed-height string with scrolling.
+func (submenu *Submenu) Render(isSubmenuOpen bool, width int, height int) string {
+	submenu.regions = submenu.regions[:0]
+	if len(submenu.items) == 0 {
+		return ""
+	}
+
 	// Render all of the computedItems to strings so we can work with their computed heights.
 	computedItems := make([]ComputedSubmenuItem, len(submenu.items))
 	for i, item := range submenu.items {
-		text := item.render(i == submenu.cursor && item.isSelectable(), isSubmenuOpen)
+		text := item.render(i == submenu.cursor && item.isSelectable(), isSubmenuOpen, width)
 
 		computedItems[i] = ComputedSubmenuItem{
 			text:   text,
@@ -464,16 +500,20 @@ func (submenu *Submenu) Render(isSubmenuOpen bool, height int) string {
 		Render("...")
 
 	var s strings.Builder
+	// Line that the next item will be written to, for tracking item regions.
+	y := 0
 
 	// Add the top overflow indicator.
 	if topOverflow {
 		s.WriteString(overflow + "\n")
+		y++
 
 		// If we have a top overflow indicator but aren't using the whole height of the screen,
 		// one of the items was oddly sized. Add some padding underneath the overflow indicator
 		// to keep all of the other items aligned to the bottom.
 		for i := 0; i < height-totalHeight; i++ {
 			s.WriteByte('\n')
+			y++
 		}
 	}
 
@@ -483,6 +523,16 @@ func (submenu *Submenu) Render(isSubmenuOpen bool, height int) string {
 			s.WriteByte('\n')
 		}
 		s.WriteString(item.text)
+
+		submenu.regions = append(submenu.regions, itemRegion{
+			index: rangeStart + i,
+			region: Region{
+				Y:      y,
+				Width:  lipgloss.Width(item.text),
+				Height: item.height,
+			},
+		})
+		y += item.height
 	}
 
 	// Add the bottom overflow indicator.
@@ -513,6 +563,19 @@ func (submenu *Submenu) CursorUp() {
 	}
 }
 
+// Move the cursor to the selectable item at the given point, relative to the last render.
+// Returns true if an item was hit.
+func (submenu *Submenu) CursorTo(x int, y int) bool {
+	i := hitTest(submenu.regions, x, y)
+	// The items may have changed since the last render, so double check the bounds.
+	if i == -1 || i >= len(submenu.items) || !submenu.items[i].isSelectable() {
+		return false
+	}
+
+	submenu.cursor = i
+	return true
+}
+
 // Reset the cursor to the first selectable item.
 func (submenu *Submenu) ResetCursor() {
 	for i, item := range submenu.items {
@@ -544,19 +607,80 @@ func (submenu *Submenu) fixCursor() {
 	}
 }
 
-// Call the currently selected item's activate callback.
+// Returns the selected item if it's currently capturing keyboard input, or nil otherwise.
+func (submenu *Submenu) editingItem() editableSubmenuItem {
+	if submenu.cursor < 0 || submenu.cursor >= len(submenu.items) {
+		return nil
+	}
+
+	item, ok := submenu.items[submenu.cursor].(editableSubmenuItem)
+	if !ok || !item.isEditing() {
+		return nil
+	}
+	return item
+}
+
+// Get the innermost open submenu, which is the one that's visible and receives input.
+func (submenu *Submenu) active() *Submenu {
+	if submenu.child == nil {
+		return submenu
+	}
+	return submenu.child.active()
+}
+
+// Get the labels of the items that opened each nested submenu, outermost first.
+func (submenu *Submenu) breadcrumbs() []string {
+	if submenu.child == nil {
+		return nil
+	}
+	return append([]string{submenu.childLabel}, submenu.child.breadcrumbs()...)
+}
+
+// Close the innermost open nested submenu. Returns false if no nested submenu was open.
+func (submenu *Submenu) closeChild() bool {
+	if submenu.child == nil {
+		return false
+	}
+	if !submenu.child.closeChild() {
+		submenu.child = nil
+	}
+	return true
+}
+
+// Close all open nested submenus. Each nested submenu keeps its own child, so they're closed
+// from the innermost out to make sure none reopens where it was left.
+func (submenu *Submenu) closeAllChildren() {
+	if submenu.child == nil {
+		return
+	}
+	submenu.child.closeAllChildren()
+	submenu.child = nil
+}
+
+// Call the currently selected item's activate callback, or open its nested submenu.
 // Returns a bubbletea command that can be run asynchronously.
 func (submenu *Submenu) Activate() tea.Cmd {
 	if submenu.cursor < 0 || submenu.cursor >= len(submenu.items) {
 		return nil
 	}
 
-	if submenu.Exclusivity == SubmenuExclusivityOne {
+	if nested, ok := submenu.items[submenu.cursor].(*NestedSubmenuItem); ok && nested.Submenu != nil {
+		submenu.child = nested.Submenu
+		submenu.childLabel = nested.Label
+		nested.Submenu.closeAllChildren()
+		nested.Submenu.ResetCursor()
+		return nested.OnActivate
+	}
+
+	item := submenu.items[submenu.cursor]
+
+	// Only toggling an item changes which one is active. Other items, like actions placed
+	// next to the toggles, leave it alone.
+	if _, ok := item.(*ToggleableSubmenuItem); ok && submenu.Exclusivity == SubmenuExclusivityOne {
 		for _, item := range submenu.items {
 			item.clearActiveFlag()
 		}
 	}
 
-	item := submenu.items[submenu.cursor]
 	return item.onActivate()
 }
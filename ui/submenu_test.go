@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"slices"
+	"testing"
+)
+
+// Build a submenu that opens a nested submenu, which opens another.
+func nestedSubmenus() (root, middle, inner *Submenu) {
+	inner = &Submenu{}
+	inner.SetItems([]SubmenuItem{&LabeledSubmenuItem{Label: "Leaf"}})
+
+	middle = &Submenu{}
+	middle.SetItems([]SubmenuItem{&NestedSubmenuItem{
+		LabeledSubmenuItem: LabeledSubmenuItem{Label: "Inner"},
+		Submenu:            inner,
+	}})
+
+	root = &Submenu{}
+	root.SetItems([]SubmenuItem{&NestedSubmenuItem{
+		LabeledSubmenuItem: LabeledSubmenuItem{Label: "Middle"},
+		Submenu:            middle,
+	}})
+	return root, middle, inner
+}
+
+func TestSubmenuNesting(t *testing.T) {
+	root, middle, inner := nestedSubmenus()
+
+	root.Activate()
+	middle.Activate()
+	if root.active() != inner {
+		t.Fatal("activating nested items didn't open the inner submenu")
+	}
+	if got, want := root.breadcrumbs(), []string{"Middle", "Inner"}; !slices.Equal(got, want) {
+		t.Errorf("got breadcrumbs %q, want %q", got, want)
+	}
+
+	// Closing one level at a time goes back out through the middle submenu.
+	root.closeChild()
+	if root.active() != middle {
+		t.Error("closeChild didn't go back to the middle submenu")
+	}
+	root.closeChild()
+	if root.active() != root || root.closeChild() {
+		t.Error("closeChild didn't go back to the root submenu")
+	}
+}
+
+func TestSubmenuReopenAfterCloseAll(t *testing.T) {
+	root, middle, _ := nestedSubmenus()
+
+	root.Activate()
+	middle.Activate()
+	root.closeAllChildren()
+	if root.active() != root {
+		t.Fatal("closeAllChildren left a nested submenu open")
+	}
+
+	// Reopening starts at the top of the nested submenu, not where it was left.
+	root.Activate()
+	if root.active() != middle {
+		t.Errorf("reopening showed %v breadcrumbs, want just the middle submenu", root.breadcrumbs())
+	}
+}
+
+func TestSubmenuReopenClearsStaleChild(t *testing.T) {
+	root, middle, _ := nestedSubmenus()
+
+	root.Activate()
+	middle.Activate()
+	// Drop the nested submenu without closing its own child.
+	root.child = nil
+
+	root.Activate()
+	if root.active() != middle {
+		t.Errorf("reopening showed %v breadcrumbs, want just the middle submenu", root.breadcrumbs())
+	}
+}
+
+func TestSubmenuExclusivity(t *testing.T) {
+	first := &ToggleableSubmenuItem{LabeledSubmenuItem: LabeledSubmenuItem{Label: "First"}, IsActive: true}
+	action := &LabeledSubmenuItem{Label: "[Action]"}
+	second := &ToggleableSubmenuItem{LabeledSubmenuItem: LabeledSubmenuItem{Label: "Second"}}
+
+	submenu := &Submenu{Exclusivity: SubmenuExclusivityOne}
+	submenu.SetItems([]SubmenuItem{first, action, second})
+
+	// Activating an action between the toggles leaves the active one alone.
+	submenu.CursorDown()
+	submenu.Activate()
+	if !first.IsActive || second.IsActive {
+		t.Errorf("after the action, got active %v %v, want only the first", first.IsActive, second.IsActive)
+	}
+
+	submenu.CursorDown()
+	submenu.Activate()
+	if first.IsActive || !second.IsActive {
+		t.Errorf("after toggling, got active %v %v, want only the second", first.IsActive, second.IsActive)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
 // Format a Duration to a human-friendly string.
@@ -45,13 +46,45 @@ func FormatBytes(bytes int64) string {
 	}
 }
 
+// Format a rate in bytes per second to a human-friendly string.
+func FormatRate(bytesPerSecond float64) string {
+	return FormatBytes(int64(math.Round(bytesPerSecond))) + "/s"
+}
+
+// Shorten a string to fit in the given width, ending it with an ellipsis if anything was cut.
+// Preserves ANSI styling.
+func Truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	return ansi.Truncate(s, width, "…")
+}
+
 // Combine a left-aligned and a right-aligned string into one fixed-width line.
 // Takes a style which is used for formatting the left-side padding, in case
-// a uniform background is required.
+// a uniform background is required. Labels that don't fit are truncated rather than wrapped.
 func RenderSplit(left string, right string, width int, style lipgloss.Style) string {
+	gap := 0
+	if right != "" {
+		gap = 1
+	}
+
+	// If both sides don't fit, shrink the right side first, but never below a third of the width.
+	if lipgloss.Width(left)+gap+lipgloss.Width(right) > width {
+		right = Truncate(right, max(width-lipgloss.Width(left)-gap, width/3))
+	}
+	left = Truncate(left, width-lipgloss.Width(right)-gap)
+
 	left = style.
 		Width(width - lipgloss.Width(right)).
 		Render(left)
 
 	return left + right
 }
+
+// Strip all styling from rendered text and draw it faint, so it appears greyed out.
+func GreyOut(s string) string {
+	return lipgloss.NewStyle().
+		Faint(true).
+		Render(ansi.Strip(s))
+}
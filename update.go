@@ -2,12 +2,28 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/neuralinkcorp/tsui/actions"
+	"github.com/neuralinkcorp/tsui/browser"
+	"github.com/neuralinkcorp/tsui/clipboard"
+	"github.com/neuralinkcorp/tsui/config"
+	"github.com/neuralinkcorp/tsui/eventlog"
+	"github.com/neuralinkcorp/tsui/export"
 	"github.com/neuralinkcorp/tsui/libts"
+	"github.com/neuralinkcorp/tsui/notify"
+	"github.com/neuralinkcorp/tsui/remote"
+	"github.com/neuralinkcorp/tsui/service"
 	"github.com/neuralinkcorp/tsui/ui"
 	"github.com/neuralinkcorp/tsui/version"
+	"tailscale.com/client/tailscale/apitype"
 	"tailscale.com/ipn"
 	"tailscale.com/ipn/ipnstate"
 	"tailscale.com/tailcfg"
@@ -16,9 +32,39 @@ import (
 // Message triggered on each main poller tick.
 type tickMsg struct{}
 
+// Message to retry reaching the daemon. Stores the retryGen it was scheduled for, and should
+// be ignored if the current retryGen is later.
+type retryMsg int
+
+// Message indicating that the daemon couldn't be reached.
+type daemonErrorMsg struct {
+	err error
+}
+
+// Message containing whether the user has write permissions to the Tailscale config.
+type canWriteMsg bool
+
+// Message indicating that the SSH connection to the remote host dropped.
+type remoteLostMsg struct {
+	err error
+}
+
+// Message containing the state of the tailscaled system service.
+type serviceStatusMsg service.Status
+
 // Message triggered on each ping poller tick.
 type pingTickMsg struct{}
 
+// Message triggered on each login poller tick. Stores the deadline of the login it was
+// started for, and should be ignored if that login is no longer in progress.
+type loginTickMsg time.Time
+
+// Message indicating that an interactive login was started.
+type loginStartedMsg struct{}
+
+// Message triggered on each DNS config poller tick.
+type dnsTickMsg struct{}
+
 // Message to increment the animation frame counter.
 type animationTickMsg struct{}
 
@@ -31,6 +77,56 @@ type pingResultsMsg map[tailcfg.StableNodeID]*ipnstate.PingResult
 // Message containing the latest version of tsui fetched from GitHub.
 type latestVersionMsg string
 
+// Message to add or remove a peer from the favorites.
+type setFavoriteMsg struct {
+	peer       *ipnstate.PeerStatus
+	isFavorite bool
+}
+
+// Message to change the sort order of the traffic submenu.
+type trafficSortMsg string
+
+// Message to change the sort order of the network devices submenu.
+type devicesSortMsg string
+
+// Message to change the grouping of the network devices submenu.
+type devicesGroupMsg string
+
+// Message to show or hide offline peers in the network devices submenu.
+type hideOfflineMsg bool
+
+// Message to change which peers the network devices submenu exports.
+type exportFilterMsg export.Filter
+
+// Message to start a netcheck.
+type startNetcheckMsg struct{}
+
+// Message containing the result of a netcheck.
+type netcheckMsg struct {
+	report *libts.NetcheckReport
+	err    error
+}
+
+// Message containing the latest DNS configuration, or the error fetching it.
+type dnsStatusMsg struct {
+	status *libts.DNSStatus
+	err    error
+}
+
+// Message containing the result of a resolver test.
+type dnsQueryMsg struct {
+	name    string
+	answers []string
+	err     error
+}
+
+// Message containing the result of a whois lookup.
+type whoisMsg struct {
+	addr string
+	resp *apitype.WhoIsResponse
+	err  error
+}
+
 // Message representing some transient error.
 type errorMsg error
 
@@ -45,45 +141,365 @@ type tipMsg string
 // ignored if the current statusGen is later.
 type statusExpiredMsg int
 
+// Record notable differences between two Tailscale states in the event log.
+func (m *model) logStateChanges(oldState libts.State, newState libts.State) {
+	if newState.BackendState != oldState.BackendState {
+		m.events.Add(eventlog.LevelInfo, fmt.Sprintf("Tailscale state changed from %s to %s",
+			oldState.BackendState, newState.BackendState))
+	}
+
+	if newState.CurrentExitNodeName != oldState.CurrentExitNodeName {
+		if newState.CurrentExitNodeName == "" {
+			m.events.Add(eventlog.LevelInfo, "Stopped using exit node "+oldState.CurrentExitNodeName)
+		} else {
+			m.events.Add(eventlog.LevelInfo, "Started using exit node "+newState.CurrentExitNodeName)
+		}
+	}
+}
+
+// Creates a command that sends desktop notifications for important changes between two
+// Tailscale states. Returns nil if there's nothing to send.
+func (m *model) makeSendStateNotifications(oldState libts.State, newState libts.State) tea.Cmd {
+	return m.makeSendNotifications(notify.StateChanges(oldState, newState,
+		m.config.Notifications, time.Duration(m.config.KeyExpiryWarning), time.Now()))
+}
+
+// Creates a command that sends desktop notifications. Returns nil if there's nothing to send
+// or notifications are unavailable.
+func (m *model) makeSendNotifications(notifications []notify.Notification) tea.Cmd {
+	if m.notifier == nil || len(notifications) == 0 {
+		return nil
+	}
+
+	notifier := m.notifier
+	return func() tea.Msg {
+		for _, notification := range notifications {
+			err := notifier.Send(notification)
+			if err != nil {
+				return errorMsg(err)
+			}
+		}
+		return nil
+	}
+}
+
 // Command that retrieves a new Tailscale state and triggers a stateMsg.
 // This will be run in a goroutine by the bubbletea runtime.
 func updateState() tea.Msg {
 	state, err := libts.GetState(ctx)
 	if err != nil {
-		return errorMsg(err)
+		return daemonErrorMsg{err}
 	}
 	return stateMsg(state)
 }
 
+// Command that checks whether the user has write permissions to the Tailscale config.
+func checkCanWrite() tea.Msg {
+	return canWriteMsg(libts.CanWrite(ctx))
+}
+
+// Creates a command that fetches the state of the tailscaled service. Returns nil if the
+// service can't be controlled.
+func makeUpdateServiceStatus(manager service.Manager) tea.Cmd {
+	if manager == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		status, err := manager.Status(ctx)
+		if err != nil {
+			// Not worth interrupting the user over.
+			return nil
+		}
+		return serviceStatusMsg(status)
+	}
+}
+
+// Run an action on the tailscaled service, like manager.Start, then fetch its new state.
+func runServiceAction(manager service.Manager, action func(context.Context) error) tea.Msg {
+	err := action(ctx)
+	if err != nil {
+		return errorMsg(fmt.Errorf("tailscaled service: %w", err))
+	}
+
+	status, err := manager.Status(ctx)
+	if err != nil {
+		return errorMsg(err)
+	}
+	return serviceStatusMsg(status)
+}
+
+// Returns true if the tailscaled service can be controlled and is installed.
+func (m *model) hasService() bool {
+	return m.serviceManager != nil && m.serviceStatus != nil && m.serviceStatus.Installed
+}
+
+// Schedule the next attempt to reach the daemon after the current retry delay.
+func (m *model) scheduleRetry() tea.Cmd {
+	m.retryGen++
+	m.retryAt = time.Now().Add(m.retryDelay)
+
+	gen := m.retryGen
+	return tea.Tick(m.retryDelay, func(_ time.Time) tea.Msg {
+		return retryMsg(gen)
+	})
+}
+
 // Command that starts the interactive login flow.
 func startLoginInteractive() tea.Msg {
 	err := libts.StartLoginInteractive(ctx)
 	if err != nil {
 		return errorMsg(err)
 	}
-	return successMsg("Starting login flow. This may take a few seconds.")
+	return loginStartedMsg{}
 }
 
 // Creates a command to gets the current latency of the specified peers. Takes some time.
 func makeDoPings(peers []*ipnstate.PeerStatus) tea.Cmd {
 	return func() tea.Msg {
 		pings := make(map[tailcfg.StableNodeID]*ipnstate.PingResult)
+		var mu sync.Mutex
+		var wg sync.WaitGroup
 
+		// Ping a few peers at a time so big tailnets finish quickly without flooding tailscaled.
+		sem := make(chan struct{}, maxConcurrentPings)
 		for _, peer := range peers {
-			ctx, cancel := context.WithTimeout(ctx, pingTimeout)
-			result, err := libts.PingPeer(ctx, peer)
-			cancel()
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+				result, err := libts.PingPeer(ctx, peer)
+				cancel()
+
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				pings[peer.ID] = result
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		return pingResultsMsg(pings)
+	}
+}
+
+// Activate the selected menu item. While the daemon is unreachable, the menu shows the last
+// known state and its actions are disabled, since they'd fail or act on stale data.
+func (m *model) activateMenu() tea.Cmd {
+	if m.daemonErr != nil {
+		return daemonUnreachableTip
+	}
+	return m.menu.Activate()
+}
+
+// Command that explains why the menu is disabled.
+func daemonUnreachableTip() tea.Msg {
+	return tipMsg("Tailscale is unreachable, so the menu is disabled until it's back.")
+}
+
+// Command that explains why an action that changes Tailscale's config is unavailable.
+func readOnlyTip() tea.Msg {
+	return tipMsg("Tailscale is in read-only mode, so you may have to run tsui as root to do that.")
+}
+
+// Start a batch of pings, unless the last one is still running. Returns nil if skipped.
+func (m *model) startPings() tea.Cmd {
+	if m.isPinging {
+		return nil
+	}
+	m.isPinging = true
+	return makeDoPings(m.pingTargets())
+}
+
+// Get the peers to gather latency from. Usually this is just the exit nodes, but sorting
+// network devices by latency needs every online peer too.
+func (m *model) pingTargets() []*ipnstate.PeerStatus {
+	if m.devicesSort != "Latency" {
+		return m.state.ExitNodes
+	}
+
+	// Offline peers would just time out, slowing down the whole batch.
+	return slices.DeleteFunc(slices.Clone(m.state.Peers), func(peer *ipnstate.PeerStatus) bool {
+		return !peer.Online && !peer.ExitNodeOption
+	})
+}
 
+// Command that has tailscaled rerun its network connectivity check. Takes a few seconds.
+func runNetcheck() tea.Msg {
+	ctx, cancel := context.WithTimeout(ctx, netcheckTimeout)
+	defer cancel()
+
+	report, err := libts.Netcheck(ctx)
+	return netcheckMsg{report: report, err: err}
+}
+
+// Command that fetches the DNS configuration.
+func updateDNSStatus() tea.Msg {
+	dnsStatus, err := libts.GetDNSStatus(ctx)
+	return dnsStatusMsg{status: dnsStatus, err: err}
+}
+
+// Resolve a name through Tailscale's DNS. Used as an input submit callback.
+func queryDNS(name string) tea.Msg {
+	ctx, cancel := context.WithTimeout(ctx, dnsQueryTimeout)
+	defer cancel()
+
+	answers, err := libts.QueryDNS(ctx, name)
+	return dnsQueryMsg{name: name, answers: answers, err: err}
+}
+
+// Look up who owns a Tailscale address. Used as an input submit callback.
+func lookupWhoIs(addr string) tea.Msg {
+	ctx, cancel := context.WithTimeout(ctx, whoisTimeout)
+	defer cancel()
+
+	resp, err := libts.WhoIs(ctx, addr)
+	return whoisMsg{addr: addr, resp: resp, err: err}
+}
+
+// Creates a command that suspends the UI and runs an interactive SSH session to a peer,
+// resuming when it exits. If user is empty, ssh picks the user.
+func makeSSHToPeer(peer *ipnstate.PeerStatus, user string) tea.Cmd {
+	host := libts.PeerHost(peer)
+	if user != "" {
+		host = user + "@" + host
+	}
+
+	// An exec.Cmd can only run once, so make a new one every time the command runs.
+	return func() tea.Msg {
+		// "--" keeps a host or user from the config being taken as an option.
+		return tea.ExecProcess(exec.Command("ssh", "--", host), func(err error) tea.Msg {
 			if err != nil {
-				continue
+				return errorMsg(fmt.Errorf("ssh: %w", err))
 			}
-			pings[peer.ID] = result
+			return nil
+		})()
+	}
+}
+
+// Creates a command that runs a custom action against a peer, suspending the UI while it
+// runs in case it's interactive.
+func makeRunAction(action config.ActionConfig, peer *ipnstate.PeerStatus) tea.Cmd {
+	// Like makeSSHToPeer, make a new process every time the command runs.
+	return func() tea.Msg {
+		return tea.ExecProcess(actions.Command(action, peer), func(err error) tea.Msg {
+			if err != nil {
+				return errorMsg(fmt.Errorf("%s: %w", action.Label, err))
+			}
+			return successMsg(fmt.Sprintf("Ran %s on %s.", action.Label, libts.PeerName(peer)))
+		})()
+	}
+}
+
+// Creates a command that exports peers to a new file in the current directory.
+func makeExportPeers(format export.Format, state libts.State, filter export.Filter, ssh config.SSHConfig) tea.Cmd {
+	return func() tea.Msg {
+		peers := export.Peers(state, filter)
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return errorMsg(err)
+		}
+		file, err := format.CreateFile(dir)
+		if err != nil {
+			return errorMsg(err)
+		}
+		err = export.Write(file, format, state, peers, ssh)
+		if err != nil {
+			file.Close()
+			return errorMsg(err)
+		}
+		err = file.Close()
+		if err != nil {
+			return errorMsg(err)
 		}
 
-		return pingResultsMsg(pings)
+		return successMsg(fmt.Sprintf("Exported %d devices to %s.", len(peers), file.Name()))
 	}
 }
 
+// Creates a command that waits for the SSH connection to the remote host to drop. Returns nil
+// if tsui isn't managing a remote host.
+func makeWatchRemote(forward *remote.Forward) tea.Cmd {
+	if forward == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		<-forward.Done()
+		return remoteLostMsg{forward.Err()}
+	}
+}
+
+// Creates a command that opens a URL in the user's web browser. The description is used in
+// the success message, like "login page".
+func makeOpenBrowser(url string, description string) tea.Cmd {
+	return func() tea.Msg {
+		err := browser.Open(url)
+		if err != nil {
+			return errorMsg(err)
+		}
+		return successMsg(fmt.Sprintf("Opened %s in your browser.", description))
+	}
+}
+
+// Creates a command that copies a value to the clipboard. The description is used in the
+// success message, like "IP address".
+func makeCopyToClipboard(value string, description string) tea.Cmd {
+	return func() tea.Msg {
+		err := clipboard.WriteString(value)
+		if err != nil {
+			return errorMsg(err)
+		}
+		return successMsg(fmt.Sprintf("Copied %s to clipboard.", description))
+	}
+}
+
+// Start or continue an interactive login. If there's already an auth URL and we can open the
+// browser ourselves, do that; otherwise, ask Tailscale to start the flow.
+func (m *model) startLogin() tea.Cmd {
+	if m.state.AuthURL != "" && browser.CanOpen() {
+		return makeOpenBrowser(m.state.AuthURL, "login page")
+	}
+	return startLoginInteractive
+}
+
+// Returns true if a reauthentication started while connected is waiting on the user, in
+// which case the login screen is shown instead of the menu.
+func (m *model) isReauthenticating() bool {
+	return m.state.BackendState == ipn.Running && !m.loginDeadline.IsZero() && m.state.AuthURL != ""
+}
+
+// Track the in-progress interactive login after a state update. Opens the browser once the
+// auth URL shows up, if we can, and finishes the login once Tailscale is running without one.
+func (m *model) updateLogin() tea.Cmd {
+	if m.loginDeadline.IsZero() {
+		return nil
+	}
+
+	if m.state.AuthURL != "" {
+		if !m.loginHasAuthURL {
+			m.loginHasAuthURL = true
+			if browser.CanOpen() {
+				return makeOpenBrowser(m.state.AuthURL, "login page")
+			}
+		}
+		return nil
+	}
+
+	if m.loginHasAuthURL && m.state.BackendState == ipn.Running {
+		m.loginDeadline = time.Time{}
+		return func() tea.Msg {
+			return successMsg("Logged in.")
+		}
+	}
+
+	return nil
+}
+
 // Command that updates the Tailscale preferences and triggers a state update.
 func editPrefs(maskedPrefs *ipn.MaskedPrefs) tea.Msg {
 	err := libts.EditPrefs(ctx, maskedPrefs)
@@ -117,11 +533,26 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case tea.KeyMsg:
+		// While a text input is focused, it gets all keys except the one to quit.
+		if m.menu.IsEditing() && msg.String() != "ctrl+c" {
+			if msg.String() == "enter" && m.daemonErr != nil {
+				return m, daemonUnreachableTip
+			}
+			return m, m.menu.HandleKey(msg)
+		}
+
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return m, tea.Quit
 		case "esc":
-			if m.menu.IsSubmenuOpen() {
+			if !m.loginDeadline.IsZero() {
+				// Stop waiting for the login. Tailscale keeps the auth URL, so it can
+				// still be completed later.
+				m.loginDeadline = time.Time{}
+				return m, func() tea.Msg {
+					return tipMsg("Stopped waiting for login.")
+				}
+			} else if m.menu.IsSubmenuOpen() {
 				m.menu.CloseSubmenu()
 			} else {
 				return m, tea.Quit
@@ -135,17 +566,73 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.menu.CursorDown()
 		case "right", "l", "d":
 			if !m.menu.IsSubmenuOpen() {
-				return m, m.menu.Activate()
+				return m, m.activateMenu()
 			}
 
 		case "enter", " ":
-			return m, m.menu.Activate()
+			return m, m.activateMenu()
+
+		// Copy hotkey for the screens shown instead of the menu. Copies the login URL, for when
+		// there's no browser on this machine, or the node key an admin needs to approve.
+		case "c":
+			if m.state.BackendState == ipn.NeedsMachineAuth && m.state.Self != nil {
+				return m, makeCopyToClipboard(m.state.Self.PublicKey.String(), "node key")
+			} else if m.state.AuthURL != "" && (m.state.BackendState != ipn.Running || m.isReauthenticating()) {
+				return m, makeCopyToClipboard(m.state.AuthURL, "login URL")
+			}
+
+		// Hotkeys for the machine approval screen.
+		case "n":
+			if m.state.BackendState == ipn.NeedsMachineAuth && m.state.Self != nil {
+				return m, makeCopyToClipboard(m.state.Self.HostName, "device name")
+			}
+		case "o":
+			if m.state.BackendState == ipn.NeedsMachineAuth && browser.CanOpen() {
+				return m, makeOpenBrowser(libts.AdminMachinesURL(m.state.Prefs), "admin console")
+			}
+
+		// Reauthenticate hotkey, advertised when the key is about to expire.
+		case "r":
+			if _, ok := m.keyExpiringSoon(); ok {
+				if m.daemonErr != nil {
+					return m, daemonUnreachableTip
+				}
+				if !m.canWrite {
+					return m, readOnlyTip
+				}
+				return m, startLoginInteractive
+			}
+
+		// Make the tailscaled service start at boot, advertised when it's unreachable.
+		case "b":
+			if m.daemonErr != nil && m.hasService() && !m.serviceStatus.IsEnabled() {
+				return m, func() tea.Msg {
+					return runServiceAction(m.serviceManager, m.serviceManager.Enable)
+				}
+			}
 
 		// Global action hotkey.
 		case ".":
+			// If tailscaled itself is unreachable, (re)start its service.
+			if m.daemonErr != nil {
+				if m.hasService() {
+					action := m.serviceManager.Start
+					if m.serviceStatus.IsActive() {
+						action = m.serviceManager.Restart
+					}
+					return m, func() tea.Msg {
+						return runServiceAction(m.serviceManager, action)
+					}
+				}
+				break
+			}
+
 			switch m.state.BackendState {
-			// If running, stop Tailscale.
+			// If running, stop Tailscale, unless we're waiting on a reauthentication.
 			case ipn.Running:
+				if m.isReauthenticating() {
+					return m, m.startLogin()
+				}
 				return m, func() tea.Msg {
 					err := libts.Down(ctx)
 					if err != nil {
@@ -166,33 +653,70 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			// If we need to login...
 			case ipn.NeedsLogin:
-				return m, startLoginInteractive
+				return m, m.startLogin()
 
 			case ipn.Starting:
 				// If we have an AuthURL in the Starting state, that means the user is reauthenticating
 				// and we want to open the browser for them (if supported).
-				if m.state.AuthURL != "" && libts.StartLoginInteractiveWillOpenBrowser() {
-					return m, startLoginInteractive
+				if m.state.AuthURL != "" {
+					return m, m.startLogin()
 				}
 			}
 		}
 
+	case tea.MouseMsg:
+		// The menu is only visible while running.
+		if m.state.BackendState != ipn.Running || m.isReauthenticating() {
+			break
+		}
+
+		menuY := m.layoutMenu()
+
+		switch {
+		case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress:
+			if m.daemonErr != nil {
+				return m, daemonUnreachableTip
+			}
+			return m, m.menu.Click(msg.X, msg.Y-menuY)
+		case msg.Button == tea.MouseButtonWheelUp:
+			m.menu.Scroll(msg.X, false)
+		case msg.Button == tea.MouseButtonWheelDown:
+			m.menu.Scroll(msg.X, true)
+		}
+
 	// On ticks, run the appropriate commands, and kick off the next tick.
 	case tickMsg:
+		// While the daemon is unreachable, retries take over polling, but the service can still
+		// change underneath us either way.
+		if m.daemonErr != nil {
+			return m, tea.Batch(
+				makeUpdateServiceStatus(m.serviceManager),
+				tea.Tick(tickInterval, func(_ time.Time) tea.Msg {
+					return tickMsg{}
+				}),
+			)
+		}
 		return m, tea.Batch(
 			updateState,
+			makeUpdateServiceStatus(m.serviceManager),
 			tea.Tick(tickInterval, func(_ time.Time) tea.Msg {
 				return tickMsg{}
 			}),
 		)
 	case pingTickMsg:
-		// For now we'll just run this on our exit nodes.
 		return m, tea.Batch(
-			makeDoPings(m.state.ExitNodes),
+			m.startPings(),
 			tea.Tick(pingTickInterval, func(_ time.Time) tea.Msg {
 				return pingTickMsg{}
 			}),
 		)
+	case dnsTickMsg:
+		return m, tea.Batch(
+			updateDNSStatus,
+			tea.Tick(dnsTickInterval, func(_ time.Time) tea.Msg {
+				return dnsTickMsg{}
+			}),
+		)
 	case animationTickMsg:
 		m.animationT++
 		return m, tea.Tick(ui.PoggersAnimationInterval, func(_ time.Time) tea.Msg {
@@ -201,12 +725,168 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// When our updaters return, update our model and refresh the menus.
 	case stateMsg:
+		var canWriteCmd tea.Cmd
+		if m.daemonErr != nil {
+			m.daemonErr = nil
+			m.retryGen++
+			m.events.Add(eventlog.LevelSuccess, "Reconnected to Tailscale")
+			// We couldn't check permissions while the daemon was unreachable.
+			canWriteCmd = checkCanWrite
+		}
+
+		m.logStateChanges(m.state, libts.State(msg))
+		cmd := m.makeSendStateNotifications(m.state, libts.State(msg))
 		m.state = libts.State(msg)
+		m.trafficStats.Update(m.state, time.Now())
 		m.updateMenus()
+		return m, tea.Batch(cmd, canWriteCmd, m.updateLogin())
+
+	case remoteLostMsg:
+		// ssh may need to prompt on the terminal to reconnect, which it can't do under the UI,
+		// so exit and let the user rerun tsui.
+		m.events.Add(eventlog.LevelError, msg.err.Error())
+		m.exitErr = msg.err
+		return m, tea.Quit
+
+	case daemonErrorMsg:
+		if m.daemonErr == nil {
+			m.events.Add(eventlog.LevelError, "Can't reach Tailscale: "+msg.err.Error())
+			m.retryDelay = minRetryDelay
+			m.daemonErr = msg.err
+			return m, tea.Batch(
+				m.scheduleRetry(),
+				makeUpdateServiceStatus(m.serviceManager),
+				m.makeSendNotifications(notify.DaemonUnreachable(m.state, msg.err, m.config.Notifications)),
+			)
+		}
+		// Otherwise, a retry is already scheduled.
+		m.daemonErr = msg.err
+	case retryMsg:
+		if int(msg) != m.retryGen || m.daemonErr == nil {
+			break
+		}
+		// Back off for next time, in case this attempt fails too.
+		m.retryDelay = min(m.retryDelay*2, maxRetryDelay)
+		return m, tea.Batch(updateState, m.scheduleRetry())
+
+	case serviceStatusMsg:
+		status := service.Status(msg)
+		m.serviceStatus = &status
+		m.updateMenus()
+
+		// If the service just came up, try reaching it again right away.
+		if m.daemonErr != nil && status.IsActive() && m.retryDelay > minRetryDelay {
+			m.retryDelay = minRetryDelay
+			return m, m.scheduleRetry()
+		}
+
+	case canWriteMsg:
+		m.canWrite = bool(msg)
+
+	case loginStartedMsg:
+		m.loginDeadline = time.Now().Add(loginTimeout)
+		m.loginHasAuthURL = false
+		return m, tea.Batch(
+			func() tea.Msg {
+				return successMsg("Starting login flow. This may take a few seconds.")
+			},
+			tea.Tick(loginTickInterval, func(_ time.Time) tea.Msg {
+				return loginTickMsg(m.loginDeadline)
+			}),
+		)
+	case loginTickMsg:
+		// Poll quickly while waiting for a login, so we notice as soon as it's done.
+		if !time.Time(msg).Equal(m.loginDeadline) {
+			break
+		}
+		if time.Now().After(m.loginDeadline) {
+			m.loginDeadline = time.Time{}
+			return m, func() tea.Msg {
+				return errorMsg(errors.New("timed out waiting for login; press . to try again"))
+			}
+		}
+		return m, tea.Batch(
+			updateState,
+			tea.Tick(loginTickInterval, func(_ time.Time) tea.Msg {
+				return msg
+			}),
+		)
 	case pingResultsMsg:
+		m.isPinging = false
 		m.pings = msg
 		m.updateMenus()
 
+	case setFavoriteMsg:
+		err := m.favorites.Set(msg.peer.ID, msg.isFavorite)
+		m.updateMenus()
+		if err != nil {
+			return m, func() tea.Msg {
+				return errorMsg(err)
+			}
+		}
+
+		text := fmt.Sprintf("Added %s to favorites.", libts.PeerName(msg.peer))
+		if !msg.isFavorite {
+			text = fmt.Sprintf("Removed %s from favorites.", libts.PeerName(msg.peer))
+		}
+		return m, func() tea.Msg {
+			return successMsg(text)
+		}
+
+	case trafficSortMsg:
+		m.trafficSort = string(msg)
+		m.updateMenus()
+
+	case devicesSortMsg:
+		m.devicesSort = string(msg)
+		m.updateMenus()
+		// Get latencies right away rather than waiting for the next tick.
+		if m.devicesSort == "Latency" {
+			return m, m.startPings()
+		}
+	case devicesGroupMsg:
+		m.devicesGroup = string(msg)
+		m.updateMenus()
+	case hideOfflineMsg:
+		m.hideOffline = bool(msg)
+		m.updateMenus()
+	case exportFilterMsg:
+		m.exportFilter = export.Filter(msg)
+		m.updateMenus()
+
+	case dnsStatusMsg:
+		// Keep showing the last known config if fetching it fails.
+		if msg.status != nil {
+			m.dnsStatus = msg.status
+		}
+		m.dnsErr = msg.err
+		m.updateMenus()
+	case dnsQueryMsg:
+		m.dnsQuery = &msg
+		m.updateMenus()
+
+	case whoisMsg:
+		m.whois = &msg
+		m.updateMenus()
+
+	case startNetcheckMsg:
+		if m.isNetcheckRunning {
+			break
+		}
+		m.isNetcheckRunning = true
+		m.updateMenus()
+		return m, runNetcheck
+	case netcheckMsg:
+		m.isNetcheckRunning = false
+		if msg.err != nil {
+			m.updateMenus()
+			return m, func() tea.Msg {
+				return errorMsg(msg.err)
+			}
+		}
+		m.netcheck = msg.report
+		m.updateMenus()
+
 	// When we get our latest version, just store it for (potential) display on exit.
 	case latestVersionMsg:
 		m.latestVersion = string(msg)
@@ -220,15 +900,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.statusType = statusTypeError
 			m.statusText = msg.Error()
 			lifetime = errorLifetime
+			m.events.Add(eventlog.LevelError, m.statusText)
 		case successMsg:
 			m.statusType = statusTypeSuccess
 			m.statusText = string(msg)
 			lifetime = successLifetime
+			m.events.Add(eventlog.LevelSuccess, m.statusText)
 		case tipMsg:
 			m.statusType = statusTypeTip
 			m.statusText = string(msg)
 			lifetime = tipLifetime
+			m.events.Add(eventlog.LevelInfo, m.statusText)
 		}
+		m.updateMenus()
 
 		m.statusGen++
 		return m, tea.Batch(
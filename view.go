@@ -3,13 +3,18 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/neuralinkcorp/tsui/browser"
 	"github.com/neuralinkcorp/tsui/libts"
 	"github.com/neuralinkcorp/tsui/ui"
 	"tailscale.com/ipn"
 )
 
+// Width of each of the throughput graphs in the bottom bar. Each character holds two samples.
+const statusBarChartWidth = 10
+
 // Format the status button in the header bar.
 func renderStatusButton(backendState ipn.State, isUsingExitNode bool) string {
 	buttonStyle := lipgloss.NewStyle().
@@ -28,6 +33,12 @@ func renderStatusButton(backendState ipn.State, isUsingExitNode bool) string {
 			Foreground(ui.Black).
 			Render("Needs Machine Auth")
 
+	case ipn.InUseOtherUser:
+		return buttonStyle.
+			Background(ui.Yellow).
+			Foreground(ui.Black).
+			Render("In Use by Another User")
+
 	case ipn.Starting:
 		return buttonStyle.
 			Background(ui.Blue).
@@ -74,13 +85,156 @@ func renderLockedOutWarning(m *model) string {
 
 	lockedOutWarning := lipgloss.NewStyle().
 		Foreground(ui.Yellow).
-		Width(80).
+		Width(min(80, m.terminalWidth)).
 		Align(lipgloss.Center).
 		Render(heading + "\n" + bodyText)
 
 	return lipgloss.PlaceHorizontal(m.terminalWidth, lipgloss.Center, lockedOutWarning)
 }
 
+// Pick the color of a key expiry warning, escalating as expiry gets closer.
+func keyExpiryColor(remaining time.Duration) lipgloss.Color {
+	switch {
+	case remaining < time.Hour:
+		return ui.Red
+	case remaining < 24*time.Hour:
+		return ui.Yellow
+	}
+	return ui.Blue
+}
+
+// Describe how long until a key expires, like "expires in 2d" or "expired".
+func formatKeyExpiry(remaining time.Duration) string {
+	if remaining <= 0 {
+		return "expired"
+	}
+	return "expires in " + ui.FormatDuration(remaining)
+}
+
+// Get how long until this device's key expires, if it's close enough to show the key
+// expiry warning.
+func (m *model) keyExpiringSoon() (time.Duration, bool) {
+	if m.state.BackendState != ipn.Running {
+		return 0, false
+	}
+	remaining, ok := libts.KeyExpiresIn(m.state.Self, time.Now())
+	return remaining, ok && remaining < time.Duration(m.config.KeyExpiryWarning)
+}
+
+// Render the key expiry warning. Should be called conditionally.
+func renderKeyExpiryWarning(m *model, remaining time.Duration) string {
+	color := keyExpiryColor(remaining)
+
+	headingText := "Key Expires in " + ui.FormatDuration(remaining)
+	if remaining <= 0 {
+		headingText = "Key Expired"
+	}
+
+	heading := lipgloss.NewStyle().
+		Background(color).
+		Foreground(ui.Black).
+		Bold(true).
+		Padding(0, 1).
+		Render(headingText)
+
+	bodyText := "Press r to reauthenticate now."
+	if !m.canWrite {
+		bodyText = "Run tsui as root to reauthenticate."
+	}
+
+	body := lipgloss.NewStyle().
+		Foreground(color).
+		PaddingLeft(1).
+		Render(bodyText)
+
+	return lipgloss.PlaceHorizontal(m.terminalWidth, lipgloss.Center, heading+body)
+}
+
+// Describe when the next attempt to reach the daemon is, like "Retrying in 4s."
+func renderRetryCountdown(m *model) string {
+	remaining := time.Until(m.retryAt).Round(time.Second)
+	if remaining <= 0 {
+		return "Retrying now..."
+	}
+	return fmt.Sprintf("Retrying in %s.", remaining)
+}
+
+// Describe the state of the tailscaled service and what can be done about it, or return an
+// empty string if it can't be controlled.
+func renderServiceHint(m *model) string {
+	if m.serviceManager == nil || m.serviceStatus == nil {
+		return ""
+	}
+
+	status := m.serviceStatus
+	if !status.Installed {
+		return "The tailscaled service isn't installed."
+	}
+
+	hint := fmt.Sprintf("The tailscaled service is %s.", status)
+	if status.IsActive() {
+		hint += " Press . to restart it."
+	} else {
+		hint += " Press . to start it."
+	}
+	if !status.IsEnabled() {
+		hint += " Press b to start it at boot."
+	}
+	return hint
+}
+
+// Render the warning shown above the greyed out last known state when the daemon becomes
+// unreachable. Should be called conditionally.
+func renderDaemonUnreachableWarning(m *model) string {
+	heading := lipgloss.NewStyle().
+		Background(ui.Red).
+		Foreground(ui.Black).
+		Bold(true).
+		Padding(0, 1).
+		Render("Can't Reach Tailscale")
+
+	bodyText := m.daemonErr.Error() + "\n" + renderRetryCountdown(m) + " Showing the last known state."
+	if hint := renderServiceHint(m); hint != "" {
+		bodyText += "\n" + hint
+	}
+
+	warning := lipgloss.NewStyle().
+		Foreground(ui.Red).
+		Width(min(80, m.terminalWidth)).
+		Align(lipgloss.Center).
+		Render(heading + "\n" + bodyText)
+
+	return lipgloss.PlaceHorizontal(m.terminalWidth, lipgloss.Center, warning)
+}
+
+// Render the screen shown when the daemon has been unreachable since tsui started.
+func renderDaemonUnreachableBanner(m *model, height int) string {
+	reason := lipgloss.NewStyle().
+		Foreground(ui.Red).
+		Width(min(80, m.terminalWidth)).
+		Align(lipgloss.Center).
+		Render(m.daemonErr.Error())
+
+	lines := []string{
+		lipgloss.NewStyle().
+			Bold(true).
+			Render(`Daemon Not Reachable`),
+		``,
+		`tsui can't connect to tailscaled. Make sure Tailscale is installed and running.`,
+		``,
+		reason,
+		``,
+	}
+	if hint := renderServiceHint(m); hint != "" {
+		lines = append(lines, hint, ``)
+	}
+	lines = append(lines, lipgloss.NewStyle().
+		Faint(true).
+		Render(renderRetryCountdown(m)))
+
+	return renderMiddleBanner(m, height, lipgloss.JoinVertical(lipgloss.Center, lines...))
+}
+
 // Format the top header section.
 func renderHeader(m *model) string {
 	logo := lipgloss.NewStyle().
@@ -91,6 +245,14 @@ func renderHeader(m *model) string {
 	var statusStr string
 	{
 		var status strings.Builder
+		if m.remoteHost != "" {
+			status.WriteString("Host:   ")
+			status.WriteString(lipgloss.NewStyle().
+				Foreground(ui.Primary).
+				Bold(true).
+				Render(m.remoteHost))
+			status.WriteByte('\n')
+		}
 		status.WriteString("Status: ")
 		status.WriteString(renderStatusButton(m.state.BackendState, m.state.CurrentExitNode != nil))
 		if m.state.BackendState == ipn.Running {
@@ -126,15 +288,26 @@ func renderHeader(m *model) string {
 		} else {
 			versions.WriteString("(not connected)")
 		}
+		if socket := libts.Socket(); socket != "" && m.remoteHost == "" {
+			versions.WriteString("\nsocket:    " + socket)
+		}
 
 		versionsStr = lipgloss.NewStyle().
 			Faint(true).
 			Render(versions.String())
 	}
 
+	// On narrow terminals, drop the less important parts of the header until it fits.
+	if lipgloss.Width(logo)+lipgloss.Width(statusStr)+lipgloss.Width(versionsStr) > m.terminalWidth {
+		versionsStr = ""
+	}
+	if lipgloss.Width(logo)+lipgloss.Width(statusStr) > m.terminalWidth {
+		logo = ""
+	}
+
 	// Spacer between the left content and the right content.
 	spacer := lipgloss.NewStyle().
-		Width(m.terminalWidth - lipgloss.Width(versionsStr) - lipgloss.Width(statusStr) - lipgloss.Width(logo)).
+		Width(max(0, m.terminalWidth-lipgloss.Width(versionsStr)-lipgloss.Width(statusStr)-lipgloss.Width(logo))).
 		Render(" ")
 
 	return lipgloss.JoinHorizontal(lipgloss.Center, logo, statusStr, spacer, versionsStr)
@@ -150,19 +323,145 @@ func renderMiddleBanner(m *model, height int, text string) string {
 		divider+"\n\n"+text+"\n\n"+divider)
 }
 
+// Render the login screen. If we can't open a browser on this machine, the login URL is also
+// shown as a QR code so it can be opened on a phone.
+func renderLoginBanner(m *model, height int, title string, description string) string {
+	lines := []string{
+		lipgloss.NewStyle().
+			Bold(true).
+			Render(title),
+		``,
+	}
+	if description != "" {
+		lines = append(lines, description, ``)
+	}
+
+	if m.state.AuthURL == "" {
+		lines = append(lines, `Press . to authenticate.`)
+	} else {
+		styledAuthUrl := lipgloss.NewStyle().
+			Underline(true).
+			Foreground(ui.Blue).
+			Render(m.state.AuthURL)
+		lines = append(lines, fmt.Sprintf(`Login URL: %s`, styledAuthUrl), ``)
+
+		if browser.CanOpen() || libts.StartLoginInteractiveWillOpenBrowser() {
+			lines = append(lines, `Press . to open in browser.`)
+		} else {
+			// Leave room for the other lines and the banner's dividers.
+			qr, err := ui.RenderQRCode(m.state.AuthURL)
+			if err == nil && lipgloss.Height(qr) <= height-len(lines)-8 && lipgloss.Width(qr) <= m.terminalWidth {
+				lines = append(lines, qr, ``, `Scan the QR code or press c to copy the URL.`)
+			} else {
+				lines = append(lines, `Press c to copy the URL.`)
+			}
+		}
+	}
+
+	if !m.loginDeadline.IsZero() {
+		remaining := time.Until(m.loginDeadline).Round(time.Second)
+		lines = append(lines, ``, lipgloss.NewStyle().
+			Faint(true).
+			Render(fmt.Sprintf(`Waiting for login (%s left). Press esc to cancel.`, remaining)))
+	}
+
+	return renderMiddleBanner(m, height, lipgloss.JoinVertical(lipgloss.Center, lines...))
+}
+
+// Render a faint note that we're polling for the state to change.
+func renderPollingNote() string {
+	return lipgloss.NewStyle().
+		Faint(true).
+		Render(fmt.Sprintf("Checking again every %s.", tickInterval))
+}
+
+// Render the screen shown while this device waits for an admin to approve it. This happens
+// when the tailnet has device approval turned on.
+func renderMachineAuthBanner(m *model, height int) string {
+	lines := []string{
+		lipgloss.NewStyle().
+			Bold(true).
+			Render(`Waiting for Device Approval`),
+		``,
+		`An admin of your tailnet needs to approve this device before it can connect.`,
+		``,
+	}
+
+	if m.state.Self != nil {
+		lines = append(lines,
+			fmt.Sprintf(`Device name: %s`, m.state.Self.HostName),
+			fmt.Sprintf(`Node key: %s`, m.state.Self.PublicKey.ShortString()),
+			``,
+		)
+	}
+
+	adminURL := lipgloss.NewStyle().
+		Underline(true).
+		Foreground(ui.Blue).
+		Render(libts.AdminMachinesURL(m.state.Prefs))
+	lines = append(lines, fmt.Sprintf(`Approve it at %s`, adminURL), ``)
+
+	hotkeys := `Press c to copy the node key or n to copy the device name.`
+	if browser.CanOpen() {
+		hotkeys = `Press c to copy the node key, n to copy the device name, or o to open the admin console.`
+	}
+	lines = append(lines, hotkeys, ``, renderPollingNote())
+
+	return renderMiddleBanner(m, height, lipgloss.JoinVertical(lipgloss.Center, lines...))
+}
+
+// Render the screen shown while another OS user is using Tailscale on this computer.
+func renderInUseOtherUserBanner(m *model, height int) string {
+	user := "another user"
+	if m.state.InUseBy != "" {
+		user = lipgloss.NewStyle().
+			Bold(true).
+			Render(m.state.InUseBy)
+	}
+
+	lines := []string{
+		lipgloss.NewStyle().
+			Bold(true).
+			Render(`Tailscale Is In Use by Another User`),
+		``,
+		fmt.Sprintf(`Tailscale on this computer is being used by %s.`, user),
+		`Only one user can control it at a time. Once they quit their Tailscale app or log out,`,
+		`tsui will connect automatically.`,
+	}
+
+	// If we couldn't figure out the user, Tailscale's own explanation may still help.
+	if m.state.InUseBy == "" && m.state.InUseMessage != "" {
+		lines = append(lines, ``, lipgloss.NewStyle().
+			Faint(true).
+			Render(m.state.InUseMessage))
+	}
+
+	lines = append(lines, ``, renderPollingNote())
+
+	return renderMiddleBanner(m, height, lipgloss.JoinVertical(lipgloss.Center, lines...))
+}
+
 // Render the bottom status bar.
 func renderStatusBar(m *model) string {
 	var text string
 
 	if m.statusText == "" && m.canWrite && m.state.BackendState == ipn.Running {
-		// If there's no other status, we're running, and we have write access, show up/down.
+		// If there's no other status, we're running, and we have write access, show up/down
+		// with a small graph of recent throughput on either side.
 		text = lipgloss.NewStyle().
+			Foreground(ui.Green).
+			Faint(true).
+			Render(ui.RenderChart(m.trafficStats.RxHistory, statusBarChartWidth, 1)) + " "
+		text += lipgloss.NewStyle().
 			Faint(true).
-			Render(fmt.Sprintf(
-				"▼ %s | %s ▲",
+			Render(formatRxTx(
 				ui.FormatBytes(m.state.RxBytes),
 				ui.FormatBytes(m.state.TxBytes),
 			))
+		text += " " + lipgloss.NewStyle().
+			Foreground(ui.Blue).
+			Faint(true).
+			Render(ui.RenderChart(m.trafficStats.TxHistory, statusBarChartWidth, 1))
 	} else if m.statusText == "" && !m.canWrite {
 		// If there's no other status and we don't have write access, show a read-only warning.
 		text = lipgloss.NewStyle().
@@ -215,6 +514,41 @@ func renderStatusBar(m *model) string {
 	return left + right
 }
 
+// Render the top of the page (header bar, locked out warning, etc).
+func renderTop(m *model) string {
+	top := renderHeader(m) + "\n\n"
+	if m.daemonErr != nil {
+		// The header shows the last known state, which may be out of date.
+		top = ui.GreyOut(top)
+		if m.state.BackendState != ipn.NoState {
+			top += renderDaemonUnreachableWarning(m) + "\n\n"
+		}
+	}
+	if m.state.IsLockedOut {
+		top += renderLockedOutWarning(m) + "\n\n"
+	}
+	if remaining, ok := m.keyExpiringSoon(); ok {
+		top += renderKeyExpiryWarning(m, remaining) + "\n\n"
+	}
+	return top + "\n"
+}
+
+// Render the bottom of the page (status bar, error text, etc).
+func renderBottom(m *model) string {
+	return "\n" + renderStatusBar(m)
+}
+
+// Re-render the main menu at its position in the page so its item regions are up-to-date,
+// and return the screen line the menu starts on. Used for mouse hit testing.
+func (m *model) layoutMenu() int {
+	top := renderTop(m)
+	middleHeight := m.terminalHeight - lipgloss.Height(top) - lipgloss.Height(renderBottom(m))
+	m.menu.Render(m.terminalWidth, middleHeight)
+
+	// The middle starts on the line right after the top, which ends in a newline.
+	return lipgloss.Height(top)
+}
+
 // Bubbletea's main render function. Called after state updates.
 func (m model) View() string {
 	// Don't render anything before we have our initial terminal info.
@@ -222,66 +556,37 @@ func (m model) View() string {
 		return ""
 	}
 
-	// Render the top of the page (header bar, locked out warning, etc).
-	top := renderHeader(&m) + "\n\n"
-	if m.state.IsLockedOut {
-		top += renderLockedOutWarning(&m) + "\n\n"
-	}
-	top += "\n"
-
-	// Render the bottom of the page (status bar, error text, etc).
-	bottom := "\n" + renderStatusBar(&m)
+	top := renderTop(&m)
+	bottom := renderBottom(&m)
 
 	// Now, draw the middle, and make it take up the remaining space.
 	middleHeight := m.terminalHeight - lipgloss.Height(top) - lipgloss.Height(bottom)
 	var middle string
 
-	styledAuthUrl := lipgloss.NewStyle().
-		Underline(true).
-		Foreground(ui.Blue).
-		Render(m.state.AuthURL)
-
 	switch m.state.BackendState {
 	case ipn.Running:
+		if m.isReauthenticating() {
+			middle = renderLoginBanner(&m, middleHeight, "Reauthenticate with Tailscale", "")
+			break
+		}
+
 		middle = lipgloss.NewStyle().
 			Height(middleHeight).
-			Render(m.menu.Render(middleHeight))
+			Render(m.menu.Render(m.terminalWidth, middleHeight))
 
 	case ipn.NeedsMachineAuth:
-		// TODO: Figure out what this state actually is so we can be helpful to the user.
-		middle = renderMiddleBanner(&m, middleHeight, "Tailscale status is NeedsMachineAuth.")
+		middle = renderMachineAuthBanner(&m, middleHeight)
 
-	case ipn.NeedsLogin:
-		lines := []string{
-			lipgloss.NewStyle().
-				Bold(true).
-				Render(`Login Required`),
-			``,
-			`You need to login to Tailscale before you can connect to the tailnet.`,
-			``,
-		}
-
-		if m.state.AuthURL == "" {
-			lines = append(lines,
-				`Press . to authenticate.`,
-			)
-		} else {
-			lines = append(lines,
-				fmt.Sprintf(`Login URL: %s`, styledAuthUrl),
-			)
-			if libts.StartLoginInteractiveWillOpenBrowser() {
-				lines = append(lines,
-					``,
-					`Press . to open in browser.`,
-				)
-			}
-		}
+	case ipn.InUseOtherUser:
+		middle = renderInUseOtherUserBanner(&m, middleHeight)
 
-		middle = renderMiddleBanner(&m, middleHeight, strings.Join(lines, "\n"))
+	case ipn.NeedsLogin:
+		middle = renderLoginBanner(&m, middleHeight, "Login Required",
+			"You need to login to Tailscale before you can connect to the tailnet.")
 
 	case ipn.Stopped:
 		middle = renderMiddleBanner(&m, middleHeight, strings.Join([]string{
-			`The Tailscale daemon isn't running.`,
+			`Tailscale is stopped.`,
 			``,
 			`Press . to bring Tailscale up.`,
 		}, "\n"))
@@ -294,23 +599,17 @@ func (m model) View() string {
 			middle = renderMiddleBanner(&m, middleHeight, ui.PoggersAnimationFrame(m.animationT))
 		} else {
 			// If we have an AuthURL in the Starting state, that means the user is reauthenticating.
-			// TODO: This case only seems to show up sometimes, and may not anymore (?) in the latest
-			// version of Tailscale.
-			lines := []string{
-				lipgloss.NewStyle().
-					Bold(true).
-					Render(`Reauthenticate with Tailscale`),
-				``,
-				fmt.Sprintf(`Login URL: %s`, styledAuthUrl),
-			}
-			if libts.StartLoginInteractiveWillOpenBrowser() {
-				// We can't open the browser for them if running as the root user on Linux.
-				lines = append(lines,
-					``,
-					`Press . to open in browser.`,
-				)
-			}
-			middle = renderMiddleBanner(&m, middleHeight, strings.Join(lines, "\n"))
+			// Newer versions of Tailscale stay Running instead, which isReauthenticating handles.
+			middle = renderLoginBanner(&m, middleHeight, "Reauthenticate with Tailscale", "")
+		}
+	}
+
+	if m.daemonErr != nil {
+		if m.state.BackendState == ipn.NoState {
+			// We've never reached the daemon, so there's no last known state to show.
+			middle = renderDaemonUnreachableBanner(&m, middleHeight)
+		} else {
+			middle = ui.GreyOut(middle)
 		}
 	}
 